@@ -0,0 +1,171 @@
+package core
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+type SubnetTier string
+
+const (
+	PublicSubnetTier   SubnetTier = "public"
+	PrivateSubnetTier  SubnetTier = "private"
+	IsolatedSubnetTier SubnetTier = "isolated"
+)
+
+const (
+	minSubnetPrefixLength = 16
+	maxSubnetPrefixLength = 28
+)
+
+// SubnetTierSpec describes one tier of subnets to carve out of the VPC range,
+// one subnet per availability zone.
+type SubnetTierSpec struct {
+	Tier         SubnetTier
+	PrefixLength int
+	// Offset places the tier's first subnet at that many subnets of its
+	// size from the start of the VPC range. 0 places it right after the
+	// previous tier.
+	Offset int
+}
+
+type SubnetAllocation struct {
+	Tier      SubnetTier
+	AzIndex   int
+	CidrBlock string
+}
+
+type CidrPlan struct {
+	VpcCidr     string
+	Allocations []SubnetAllocation
+}
+
+// Tier returns the CIDR blocks allocated to a tier, ordered by availability zone.
+func (p *CidrPlan) Tier(tier SubnetTier) []string {
+	var blocks []string
+	for _, allocation := range p.Allocations {
+		if allocation.Tier == tier {
+			blocks = append(blocks, allocation.CidrBlock)
+		}
+	}
+	return blocks
+}
+
+// PlanSubnets carves azCount subnets per tier out of vpcCidr. Tiers are laid
+// out in the order given, from their offset or after the previous tier, each
+// subnet aligned to its own prefix length, and
+// the resulting plan is validated before it is returned so a bad range fails
+// the program before any AWS call is made.
+func PlanSubnets(vpcCidr string, azCount int, tiers []SubnetTierSpec) (*CidrPlan, error) {
+	vpc, err := netip.ParsePrefix(vpcCidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vpc range %q: %w", vpcCidr, err)
+	}
+	if !vpc.Addr().Is4() {
+		return nil, fmt.Errorf("vpc range %q must be an IPv4 CIDR", vpcCidr)
+	}
+	if vpc.Masked() != vpc {
+		return nil, fmt.Errorf("vpc range %q is not a network address, expected %s", vpcCidr, vpc.Masked())
+	}
+	if azCount < 1 {
+		return nil, fmt.Errorf("at least one availability zone is required, got %d", azCount)
+	}
+
+	plan := &CidrPlan{VpcCidr: vpc.String()}
+	vpcStart := ipv4ToUint(vpc.Addr())
+	vpcEnd := vpcStart + blockSize(vpc.Bits())
+	cursor := vpcStart
+
+	for _, spec := range tiers {
+		if spec.PrefixLength < vpc.Bits() || spec.PrefixLength < minSubnetPrefixLength || spec.PrefixLength > maxSubnetPrefixLength {
+			return nil, fmt.Errorf("%s subnet prefix length /%d must be between /%d and /%d",
+				spec.Tier, spec.PrefixLength, maxInt(vpc.Bits(), minSubnetPrefixLength), maxSubnetPrefixLength)
+		}
+
+		size := blockSize(spec.PrefixLength)
+		if spec.Offset < 0 {
+			return nil, fmt.Errorf("%s subnet offset must not be negative, got %d", spec.Tier, spec.Offset)
+		}
+		if spec.Offset > 0 {
+			start := vpcStart + uint64(spec.Offset)*size
+			if start < cursor {
+				return nil, fmt.Errorf("%s subnets at offset %d of /%d would start at %s, inside the previous tier",
+					spec.Tier, spec.Offset, spec.PrefixLength, uintToIpv4(start))
+			}
+			cursor = start
+		}
+		for az := 0; az < azCount; az++ {
+			cursor = alignUp(cursor, size)
+			if cursor+size > vpcEnd {
+				return nil, fmt.Errorf("vpc range %s is too small for %d %s subnets of /%d",
+					plan.VpcCidr, azCount, spec.Tier, spec.PrefixLength)
+			}
+			block := netip.PrefixFrom(uintToIpv4(cursor), spec.PrefixLength)
+			plan.Allocations = append(plan.Allocations, SubnetAllocation{
+				Tier:      spec.Tier,
+				AzIndex:   az,
+				CidrBlock: block.String(),
+			})
+			cursor += size
+		}
+	}
+
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// Validate checks that every allocation sits inside the VPC range and that no
+// two allocations overlap.
+func (p *CidrPlan) Validate() error {
+	vpc, err := netip.ParsePrefix(p.VpcCidr)
+	if err != nil {
+		return fmt.Errorf("invalid vpc range %q: %w", p.VpcCidr, err)
+	}
+
+	prefixes := make([]netip.Prefix, len(p.Allocations))
+	for i, allocation := range p.Allocations {
+		prefix, err := netip.ParsePrefix(allocation.CidrBlock)
+		if err != nil {
+			return fmt.Errorf("invalid %s subnet range %q: %w", allocation.Tier, allocation.CidrBlock, err)
+		}
+		if prefix.Bits() < vpc.Bits() || !vpc.Contains(prefix.Addr()) {
+			return fmt.Errorf("%s subnet %s is outside vpc range %s", allocation.Tier, prefix, vpc)
+		}
+		for j := 0; j < i; j++ {
+			if prefixes[j].Overlaps(prefix) {
+				return fmt.Errorf("%s subnet %s overlaps %s subnet %s",
+					allocation.Tier, prefix, p.Allocations[j].Tier, prefixes[j])
+			}
+		}
+		prefixes[i] = prefix
+	}
+
+	return nil
+}
+
+func blockSize(prefixLength int) uint64 {
+	return uint64(1) << (32 - prefixLength)
+}
+
+func alignUp(value, size uint64) uint64 {
+	return (value + size - 1) / size * size
+}
+
+func ipv4ToUint(addr netip.Addr) uint64 {
+	b := addr.As4()
+	return uint64(b[0])<<24 | uint64(b[1])<<16 | uint64(b[2])<<8 | uint64(b[3])
+}
+
+func uintToIpv4(value uint64) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)})
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanSubnets(t *testing.T) {
+	plan, err := PlanSubnets("10.1.0.0/16", 2, []SubnetTierSpec{
+		{Tier: PublicSubnetTier, PrefixLength: 24},
+		{Tier: PrivateSubnetTier, PrefixLength: 22},
+		{Tier: IsolatedSubnetTier, PrefixLength: 26},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tier SubnetTier
+		want []string
+	}{
+		{PublicSubnetTier, []string{"10.1.0.0/24", "10.1.1.0/24"}},
+		{PrivateSubnetTier, []string{"10.1.4.0/22", "10.1.8.0/22"}},
+		{IsolatedSubnetTier, []string{"10.1.12.0/26", "10.1.12.64/26"}},
+	}
+	for _, tt := range tests {
+		if got := plan.Tier(tt.tier); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s subnets = %v, want %v", tt.tier, got, tt.want)
+		}
+	}
+}
+
+func TestPlanSubnetsOffsets(t *testing.T) {
+	plan, err := PlanSubnets("10.1.0.0/16", 2, []SubnetTierSpec{
+		{Tier: PublicSubnetTier, PrefixLength: 24, Offset: 1},
+		{Tier: PrivateSubnetTier, PrefixLength: 24, Offset: 11},
+		{Tier: IsolatedSubnetTier, PrefixLength: 26},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tier SubnetTier
+		want []string
+	}{
+		{PublicSubnetTier, []string{"10.1.1.0/24", "10.1.2.0/24"}},
+		{PrivateSubnetTier, []string{"10.1.11.0/24", "10.1.12.0/24"}},
+		{IsolatedSubnetTier, []string{"10.1.13.0/26", "10.1.13.64/26"}},
+	}
+	for _, tt := range tests {
+		if got := plan.Tier(tt.tier); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s subnets = %v, want %v", tt.tier, got, tt.want)
+		}
+	}
+}
+
+func TestPlanSubnetsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		vpcCidr string
+		azCount int
+		tiers   []SubnetTierSpec
+	}{
+		{"invalid range", "10.1.0.0", 2, []SubnetTierSpec{{Tier: PublicSubnetTier, PrefixLength: 24}}},
+		{"host bits set", "10.1.1.0/16", 2, []SubnetTierSpec{{Tier: PublicSubnetTier, PrefixLength: 24}}},
+		{"ipv6", "2001:db8::/56", 2, []SubnetTierSpec{{Tier: PublicSubnetTier, PrefixLength: 64}}},
+		{"no zones", "10.1.0.0/16", 0, []SubnetTierSpec{{Tier: PublicSubnetTier, PrefixLength: 24}}},
+		{"subnet larger than vpc", "10.1.0.0/24", 2, []SubnetTierSpec{{Tier: PublicSubnetTier, PrefixLength: 20}}},
+		{"subnet too small", "10.1.0.0/16", 2, []SubnetTierSpec{{Tier: PublicSubnetTier, PrefixLength: 29}}},
+		{"negative offset", "10.1.0.0/16", 2, []SubnetTierSpec{{Tier: PublicSubnetTier, PrefixLength: 24, Offset: -1}}},
+		{"offset inside previous tier", "10.1.0.0/16", 2, []SubnetTierSpec{{Tier: PublicSubnetTier, PrefixLength: 24, Offset: 1}, {Tier: PrivateSubnetTier, PrefixLength: 24, Offset: 2}}},
+		{"range exhausted", "10.1.0.0/24", 3, []SubnetTierSpec{{Tier: PublicSubnetTier, PrefixLength: 26}, {Tier: PrivateSubnetTier, PrefixLength: 26}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := PlanSubnets(tt.vpcCidr, tt.azCount, tt.tiers); err == nil {
+				t.Errorf("PlanSubnets(%q, %d) succeeded, want an error", tt.vpcCidr, tt.azCount)
+			}
+		})
+	}
+}
+
+func TestCidrPlanValidate(t *testing.T) {
+	plan := &CidrPlan{
+		VpcCidr: "10.1.0.0/16",
+		Allocations: []SubnetAllocation{
+			{Tier: PublicSubnetTier, CidrBlock: "10.1.0.0/23"},
+			{Tier: PrivateSubnetTier, CidrBlock: "10.1.1.0/24"},
+		},
+	}
+	if err := plan.Validate(); err == nil {
+		t.Error("overlapping subnets passed validation")
+	}
+
+	plan.Allocations[1].CidrBlock = "10.2.0.0/24"
+	if err := plan.Validate(); err == nil {
+		t.Error("subnet outside the vpc range passed validation")
+	}
+}
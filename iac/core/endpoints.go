@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// gatewayEndpoints are routed through the route tables and cost nothing.
+var gatewayEndpoints = map[string]bool{"s3": true, "dynamodb": true}
+
+// interfaceEndpoints get an ENI in every private subnet and private DNS, so
+// the public service hostnames resolve to them.
+var interfaceEndpoints = map[string]bool{
+	"ecr.api":        true,
+	"ecr.dkr":        true,
+	"logs":           true,
+	"secretsmanager": true,
+	"ssm":            true,
+	"sts":            true,
+}
+
+// vpcEndpointNames validates the endpoints listed in network:vpcEndpoints.
+// Isolated networks get every supported endpoint, since without NAT they
+// are the only way for tasks to pull images and ship logs.
+func vpcEndpointNames(configured []string, natStrategy NatStrategy) ([]string, error) {
+	seen := map[string]bool{}
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range configured {
+		if !gatewayEndpoints[name] && !interfaceEndpoints[name] {
+			return nil, fmt.Errorf("network:vpcEndpoints: unsupported endpoint %q, expected one of %s", name, strings.Join(supportedEndpoints(), ", "))
+		}
+		add(name)
+	}
+	if natStrategy == NatStrategyIsolated {
+		for _, name := range supportedEndpoints() {
+			add(name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func hasInterfaceEndpoint(names []string) bool {
+	for _, name := range names {
+		if interfaceEndpoints[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func supportedEndpoints() []string {
+	var names []string
+	for name := range gatewayEndpoints {
+		names = append(names, name)
+	}
+	for name := range interfaceEndpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newVpcEndpoints creates the named endpoints. Gateway endpoints are added to
+// routeTableIds, interface endpoints are placed in subnetIds behind a
+// security group that accepts HTTPS from the VPC.
+func newVpcEndpoints(ctx *pulumi.Context, name string, names []string, vpcId pulumi.StringInput, vpcCidr string, subnetIds pulumi.StringArrayInput, routeTableIds pulumi.StringArrayInput, parent pulumi.Resource) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	region, err := aws.GetRegion(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("getting region: %w", err)
+	}
+
+	var endpointSecurityGroupIds pulumi.StringArray
+	if hasInterfaceEndpoint(names) {
+		endpointSecurityGroup, err := ec2.NewSecurityGroup(ctx, name+"-vpc-endpoint-security-group", &ec2.SecurityGroupArgs{
+			VpcId: vpcId,
+			Tags: pulumi.StringMap{
+				"Name": pulumi.String("vpc endpoints allow https from vpc"),
+			},
+		}, pulumi.Parent(parent))
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", name+"-vpc-endpoint-security-group", err)
+		}
+
+		_, err = NewSecurityGroupRules(ctx, name+"-vpc-endpoint", endpointSecurityGroup.ID(), []SecurityGroupRule{
+			{Name: "ingress-443-vpc", Direction: IngressRule, FromPort: 443, ToPort: 443, CidrBlocks: []string{vpcCidr}, Description: "HTTPS from the VPC"},
+		}, pulumi.Parent(parent))
+		if err != nil {
+			return err
+		}
+		endpointSecurityGroupIds = pulumi.StringArray{endpointSecurityGroup.ID()}
+	}
+
+	for _, endpoint := range names {
+		args := &ec2.VpcEndpointArgs{
+			VpcId:       vpcId,
+			ServiceName: pulumi.String("com.amazonaws." + region.Name + "." + endpoint),
+			Tags: pulumi.StringMap{
+				"Name": pulumi.String(name + "-" + endpoint),
+			},
+		}
+		if gatewayEndpoints[endpoint] {
+			args.VpcEndpointType = pulumi.String("Gateway")
+			args.RouteTableIds = routeTableIds
+		} else {
+			args.VpcEndpointType = pulumi.String("Interface")
+			args.SubnetIds = subnetIds
+			args.SecurityGroupIds = endpointSecurityGroupIds
+			args.PrivateDnsEnabled = pulumi.Bool(true)
+		}
+
+		_, err = ec2.NewVpcEndpoint(ctx, name+"-"+strings.ReplaceAll(endpoint, ".", "-")+"-endpoint", args, pulumi.Parent(parent))
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", name+"-"+strings.ReplaceAll(endpoint, ".", "-")+"-endpoint", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"air-tek-iac/utils"
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type FlowLogDestination string
+
+const (
+	CloudWatchFlowLogs FlowLogDestination = "cloudwatch"
+	S3FlowLogs         FlowLogDestination = "s3"
+)
+
+// FlowLogsArgs is read from network:flowLogs.
+type FlowLogsArgs struct {
+	Destination FlowLogDestination `json:"destination"`
+	// TrafficType is ALL, ACCEPT or REJECT. Defaults to ALL.
+	TrafficType string `json:"trafficType"`
+	// RetentionInDays applies to the log group or, for S3, expires the log
+	// objects. Defaults to 30, zero keeps them forever.
+	RetentionInDays *int `json:"retentionInDays"`
+}
+
+func (a *FlowLogsArgs) validate() error {
+	if a.Destination != CloudWatchFlowLogs && a.Destination != S3FlowLogs {
+		return fmt.Errorf("network:flowLogs: destination must be %q or %q, got %q", CloudWatchFlowLogs, S3FlowLogs, a.Destination)
+	}
+	switch a.TrafficType {
+	case "":
+		a.TrafficType = "ALL"
+	case "ALL", "ACCEPT", "REJECT":
+	default:
+		return fmt.Errorf("network:flowLogs: trafficType must be ALL, ACCEPT or REJECT, got %q", a.TrafficType)
+	}
+	if a.RetentionInDays == nil {
+		days := 30
+		a.RetentionInDays = &days
+	}
+	if *a.RetentionInDays < 0 {
+		return fmt.Errorf("network:flowLogs: retentionInDays must not be negative")
+	}
+	if a.Destination == CloudWatchFlowLogs {
+		if err := utils.ValidateLogRetention(*a.RetentionInDays); err != nil {
+			return fmt.Errorf("network:flowLogs: %w", err)
+		}
+	}
+	return nil
+}
+
+const flowLogsAssumeRolePolicy = `{
+"Version": "2012-10-17",
+"Statement": [{
+	"Effect": "Allow",
+	"Principal": {
+		"Service": "vpc-flow-logs.amazonaws.com"
+	},
+	"Action": "sts:AssumeRole"
+}]
+}`
+
+// newFlowLogs records the VPC's traffic in a log group or bucket and returns
+// its name.
+func newFlowLogs(ctx *pulumi.Context, name string, args *FlowLogsArgs, vpcId pulumi.StringInput, parent pulumi.Resource) (pulumi.StringOutput, error) {
+	flowLogArgs := &ec2.FlowLogArgs{
+		VpcId:       vpcId,
+		TrafficType: pulumi.String(args.TrafficType),
+	}
+
+	var destinationName pulumi.StringOutput
+	switch args.Destination {
+	case CloudWatchFlowLogs:
+		logGroup, err := cloudwatch.NewLogGroup(ctx, name+"-flow-logs", &cloudwatch.LogGroupArgs{
+			RetentionInDays: pulumi.Int(*args.RetentionInDays),
+		}, pulumi.Parent(parent))
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", name+"-flow-logs", err)
+		}
+
+		role, err := iam.NewRole(ctx, name+"-flow-logs-role", &iam.RoleArgs{
+			AssumeRolePolicy: pulumi.String(flowLogsAssumeRolePolicy),
+		}, pulumi.Parent(parent))
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", name+"-flow-logs-role", err)
+		}
+
+		_, err = iam.NewRolePolicy(ctx, name+"-flow-logs-policy", &iam.RolePolicyArgs{
+			Role: role.Name,
+			Policy: pulumi.Sprintf(`{
+"Version": "2012-10-17",
+"Statement": [{
+	"Effect": "Allow",
+	"Action": ["logs:CreateLogStream", "logs:PutLogEvents", "logs:DescribeLogGroups", "logs:DescribeLogStreams"],
+	"Resource": ["%s", "%s:*"]
+}]
+}`, logGroup.Arn, logGroup.Arn),
+		}, pulumi.Parent(parent))
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", name+"-flow-logs-policy", err)
+		}
+
+		flowLogArgs.LogDestinationType = pulumi.String("cloud-watch-logs")
+		flowLogArgs.LogDestination = logGroup.Arn
+		flowLogArgs.IamRoleArn = role.Arn
+		destinationName = logGroup.Name
+
+	case S3FlowLogs:
+		bucket, err := s3.NewBucketV2(ctx, name+"-flow-logs", nil, pulumi.Parent(parent))
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", name+"-flow-logs", err)
+		}
+
+		_, err = s3.NewBucketPublicAccessBlock(ctx, name+"-flow-logs-public-access-block", &s3.BucketPublicAccessBlockArgs{
+			Bucket:                bucket.ID(),
+			BlockPublicAcls:       pulumi.Bool(true),
+			BlockPublicPolicy:     pulumi.Bool(true),
+			IgnorePublicAcls:      pulumi.Bool(true),
+			RestrictPublicBuckets: pulumi.Bool(true),
+		}, pulumi.Parent(parent))
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", name+"-flow-logs-public-access-block", err)
+		}
+
+		if *args.RetentionInDays > 0 {
+			_, err = s3.NewBucketLifecycleConfigurationV2(ctx, name+"-flow-logs-lifecycle", &s3.BucketLifecycleConfigurationV2Args{
+				Bucket: bucket.ID(),
+				Rules: s3.BucketLifecycleConfigurationV2RuleArray{
+					&s3.BucketLifecycleConfigurationV2RuleArgs{
+						Id:     pulumi.String("expire-flow-logs"),
+						Status: pulumi.String("Enabled"),
+						Filter: &s3.BucketLifecycleConfigurationV2RuleFilterArgs{},
+						Expiration: &s3.BucketLifecycleConfigurationV2RuleExpirationArgs{
+							Days: pulumi.Int(*args.RetentionInDays),
+						},
+					},
+				},
+			}, pulumi.Parent(parent))
+			if err != nil {
+				return pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", name+"-flow-logs-lifecycle", err)
+			}
+		}
+
+		flowLogArgs.LogDestinationType = pulumi.String("s3")
+		flowLogArgs.LogDestination = bucket.Arn
+		destinationName = bucket.Bucket
+	}
+
+	_, err := ec2.NewFlowLog(ctx, name+"-flow-log", flowLogArgs, pulumi.Parent(parent))
+	if err != nil {
+		return pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", name+"-flow-log", err)
+	}
+
+	return destinationName, nil
+}
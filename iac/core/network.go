@@ -1,6 +1,9 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
@@ -10,387 +13,463 @@ import (
 type Network struct {
 	pulumi.ResourceState
 
-	NetworkName                       string              `pulumi:"NetworkName"`
-	VpcId                             pulumi.StringOutput `pulumi:"VpcId"`
-	PublicSubnet1aId                  pulumi.StringOutput `pulumi:"PublicSubnet1aId"`
-	PublicSubnet1bId                  pulumi.StringOutput `pulumi:"PublicSubnet1bId"`
-	PrivateSubnet1aId                 pulumi.StringOutput `pulumi:"PrivateSubnet1aId"`
-	PrivateSubnet1bId                 pulumi.StringOutput `pulumi:"PrivateSubnet1bId"`
-	WebUiEc2InstanceSecurityGroupId   pulumi.StringOutput `pulumi:"WebUiEc2InstanceSecurityGroupId"`
-	WebUiLoadBalancerSecurityGroupId  pulumi.StringOutput `pulumi:"WebUiLoadBalancerSecurityGroupId"`
-	WebApiEc2InstanceSecurityGroupId  pulumi.StringOutput `pulumi:"WebAPiEc2InstanceSecurityGroupId"`
-	WebApiLoadBalancerSecurityGroupId pulumi.StringOutput `pulumi:"WebApiLoadBalancerSecurityGroupId"`
+	NetworkName                       string                   `pulumi:"NetworkName"`
+	VpcId                             pulumi.StringOutput      `pulumi:"VpcId"`
+	PublicSubnetIds                   pulumi.StringArrayOutput `pulumi:"PublicSubnetIds"`
+	PrivateSubnetIds                  pulumi.StringArrayOutput `pulumi:"PrivateSubnetIds"`
+	IsolatedSubnetIds                 pulumi.StringArrayOutput `pulumi:"IsolatedSubnetIds"`
+	WebUiEc2InstanceSecurityGroupId   pulumi.StringOutput      `pulumi:"WebUiEc2InstanceSecurityGroupId"`
+	WebUiLoadBalancerSecurityGroupId  pulumi.StringOutput      `pulumi:"WebUiLoadBalancerSecurityGroupId"`
+	WebApiEc2InstanceSecurityGroupId  pulumi.StringOutput      `pulumi:"WebAPiEc2InstanceSecurityGroupId"`
+	WebApiLoadBalancerSecurityGroupId pulumi.StringOutput      `pulumi:"WebApiLoadBalancerSecurityGroupId"`
+	// FlowLogsDestination is the flow log group or bucket name, empty when
+	// flow logs are off.
+	FlowLogsDestination pulumi.StringOutput `pulumi:"FlowLogsDestination"`
 }
 
-func NewNetwork(ctx *pulumi.Context, opts ...pulumi.ResourceOption) (*Network, error) {
+const maxAzCount = 6
+
+// NatStrategy controls how private subnets reach the internet.
+type NatStrategy string
+
+const (
+	// NatStrategySingle shares one NAT gateway across every private subnet.
+	NatStrategySingle NatStrategy = "single"
+	// NatStrategyPerAz creates a NAT gateway and route table in each zone so
+	// egress survives the loss of a single zone.
+	NatStrategyPerAz NatStrategy = "per-az"
+	// NatStrategyNone gives private subnets no route to the internet.
+	NatStrategyNone NatStrategy = "none"
+	// NatStrategyIsolated has no NAT either but adds every supported VPC
+	// endpoint, so Fargate tasks can still reach ECR, S3 and CloudWatch Logs.
+	NatStrategyIsolated NatStrategy = "isolated"
+)
+
+func NewNetwork(ctx *pulumi.Context, opts ...pulumi.ResourceOption) (_ *Network, err error) {
 
 	var resource Network
 
 	config := config.New(ctx, "network")
 
 	networkName := config.Require("name")
+	vpcRange := config.Require("vpcRange")
 
-	err := ctx.RegisterComponentResource("air-tek:infra:network", networkName, &resource, opts...)
+	publicPrefixLength, err := configIntOrDefault(config, "publicSubnetPrefixLength", 24)
 	if err != nil {
 		return nil, err
 	}
-
-	vpc, err := ec2.NewVpc(ctx, networkName+"-vpc", &ec2.VpcArgs{
-		CidrBlock:          pulumi.String(config.Require("vpcRange")),
-		EnableDnsSupport:   pulumi.Bool(true),
-		EnableDnsHostnames: pulumi.Bool(true),
-		InstanceTenancy:    pulumi.String("default"),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
-	}, pulumi.Parent(&resource))
-
+	privatePrefixLength, err := configIntOrDefault(config, "privateSubnetPrefixLength", 24)
 	if err != nil {
 		return nil, err
 	}
-
-	availabilityZones, err := aws.GetAvailabilityZones(ctx, nil)
+	isolatedPrefixLength, err := configIntOrDefault(config, "isolatedSubnetPrefixLength", 0)
 	if err != nil {
 		return nil, err
 	}
-
-	publicSubnet1a, err := ec2.NewSubnet(ctx, networkName+"-public-subnet-1a", &ec2.SubnetArgs{
-		VpcId:               vpc.ID(),
-		CidrBlock:           pulumi.String("10.1.1.0/24"),
-		MapPublicIpOnLaunch: pulumi.Bool(true),
-		AvailabilityZone:    pulumi.String(availabilityZones.Names[0]),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
-	}, pulumi.Parent(&resource))
+	// The default offsets keep the layout the network has always had,
+	// public subnets from 10.x.1.0/24 and private ones from 10.x.11.0/24, so
+	// existing subnets aren't replaced.
+	publicOffset, err := configIntOrDefault(config, "publicSubnetOffset", 1)
 	if err != nil {
 		return nil, err
 	}
-
-	publicSubnet1b, err := ec2.NewSubnet(ctx, networkName+"-public-subnet-1b", &ec2.SubnetArgs{
-		VpcId:               vpc.ID(),
-		CidrBlock:           pulumi.String("10.1.2.0/24"),
-		MapPublicIpOnLaunch: pulumi.Bool(true),
-		AvailabilityZone:    pulumi.String(availabilityZones.Names[1]),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
-	}, pulumi.Parent(&resource))
+	privateOffset, err := configIntOrDefault(config, "privateSubnetOffset", 11)
 	if err != nil {
 		return nil, err
 	}
-
-	privateSubnet1a, err := ec2.NewSubnet(ctx, networkName+"-private-subnet-1a", &ec2.SubnetArgs{
-		VpcId:               vpc.ID(),
-		CidrBlock:           pulumi.String("10.1.11.0/24"),
-		MapPublicIpOnLaunch: pulumi.Bool(false),
-		AvailabilityZone:    pulumi.String(availabilityZones.Names[0]),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
-	}, pulumi.Parent(&resource))
+	isolatedOffset, err := configIntOrDefault(config, "isolatedSubnetOffset", 0)
 	if err != nil {
 		return nil, err
 	}
+	tiers := []SubnetTierSpec{
+		{Tier: PublicSubnetTier, PrefixLength: publicPrefixLength, Offset: publicOffset},
+		{Tier: PrivateSubnetTier, PrefixLength: privatePrefixLength, Offset: privateOffset},
+	}
+	if isolatedPrefixLength > 0 {
+		tiers = append(tiers, SubnetTierSpec{Tier: IsolatedSubnetTier, PrefixLength: isolatedPrefixLength, Offset: isolatedOffset})
+	}
 
-	privateSubnet1b, err := ec2.NewSubnet(ctx, networkName+"-private-subnet-1b", &ec2.SubnetArgs{
-		VpcId:               vpc.ID(),
-		CidrBlock:           pulumi.String("10.1.12.0/24"),
-		MapPublicIpOnLaunch: pulumi.Bool(false),
-		AvailabilityZone:    pulumi.String(availabilityZones.Names[1]),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
-	}, pulumi.Parent(&resource))
+	natStrategy := NatStrategy(config.Get("natStrategy"))
+	if natStrategy == "" {
+		natStrategy = NatStrategySingle
+	}
+	if natStrategy != NatStrategySingle && natStrategy != NatStrategyPerAz && natStrategy != NatStrategyNone && natStrategy != NatStrategyIsolated {
+		return nil, fmt.Errorf("network:natStrategy must be one of %q, %q, %q or %q, got %q",
+			NatStrategySingle, NatStrategyPerAz, NatStrategyNone, NatStrategyIsolated, natStrategy)
+	}
+
+	var configuredEndpoints []string
+	if err := config.TryObject("vpcEndpoints", &configuredEndpoints); err != nil && config.Get("vpcEndpoints") != "" {
+		return nil, fmt.Errorf("network:vpcEndpoints must be a list of endpoint names: %w", err)
+	}
+	vpcEndpoints, err := vpcEndpointNames(configuredEndpoints, natStrategy)
 	if err != nil {
 		return nil, err
 	}
 
-	igw, err := ec2.NewInternetGateway(ctx, networkName+"-igw", &ec2.InternetGatewayArgs{
-		VpcId: vpc.ID(),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
-	}, pulumi.Parent(&resource))
+	azCount, err := configIntOrDefault(config, "azCount", 2)
 	if err != nil {
 		return nil, err
 	}
+	if azCount < 1 || azCount > maxAzCount {
+		return nil, fmt.Errorf("network:azCount must be between 1 and %d, got %d", maxAzCount, azCount)
+	}
 
-	publicRouteTable, err := ec2.NewRouteTable(ctx, networkName+"-public-route-table", &ec2.RouteTableArgs{
-		VpcId: vpc.ID(),
-		Routes: ec2.RouteTableRouteArray{
-			&ec2.RouteTableRouteArgs{
-				CidrBlock: pulumi.String("0.0.0.0/0"),
-				GatewayId: igw.ID(),
-			},
-		},
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
-	}, pulumi.Parent(&resource))
+	var flowLogs *FlowLogsArgs
+	if err := config.TryObject("flowLogs", &flowLogs); err != nil && config.Get("flowLogs") != "" {
+		return nil, fmt.Errorf("network:flowLogs: %w", err)
+	}
+	if flowLogs != nil {
+		if err := flowLogs.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	ingressAllowLists, err := loadIngressAllowLists(config)
 	if err != nil {
 		return nil, err
 	}
-	_, err = ec2.NewRouteTableAssociation(ctx, networkName+"-public-subnet-1a-route-table-association", &ec2.RouteTableAssociationArgs{
-		SubnetId:     publicSubnet1a.ID(),
-		RouteTableId: publicRouteTable.ID(),
-	}, pulumi.Parent(&resource))
+
+	cidrPlan, err := PlanSubnets(vpcRange, azCount, tiers)
 	if err != nil {
 		return nil, err
 	}
+	publicCidrs := cidrPlan.Tier(PublicSubnetTier)
+	privateCidrs := cidrPlan.Tier(PrivateSubnetTier)
+	isolatedCidrs := cidrPlan.Tier(IsolatedSubnetTier)
 
-	_, err = ec2.NewRouteTableAssociation(ctx, networkName+"-public-subnet-1b-route-table-association", &ec2.RouteTableAssociationArgs{
-		SubnetId:     publicSubnet1b.ID(),
-		RouteTableId: publicRouteTable.ID(),
-	}, pulumi.Parent(&resource))
+	// Configuration errors above name their key, the errors from here on are
+	// about the network's resources.
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("network %s: %w", networkName, err)
+		}
+	}()
+
+	availabilityZones, err := aws.GetAvailabilityZones(ctx, &aws.GetAvailabilityZonesArgs{
+		State: pulumi.StringRef("available"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting availability zones: %w", err)
+	}
+	if len(availabilityZones.Names) < azCount {
+		return nil, fmt.Errorf("network:azCount is %d but the region only has %d availability zones", azCount, len(availabilityZones.Names))
+	}
+
+	err = ctx.RegisterComponentResource("air-tek:infra:network", networkName, &resource, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	natGatewayEip, err := ec2.NewEip(ctx, networkName+"-nat-gw-eip", &ec2.EipArgs{
-		Vpc: pulumi.Bool(true),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
+	vpc, err := ec2.NewVpc(ctx, networkName+"-vpc", &ec2.VpcArgs{
+		CidrBlock:          pulumi.String(vpcRange),
+		EnableDnsSupport:   pulumi.Bool(true),
+		EnableDnsHostnames: pulumi.Bool(true),
+		InstanceTenancy:    pulumi.String("default"),
 	}, pulumi.Parent(&resource))
+
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating %s: %w", networkName+"-vpc", err)
 	}
 
-	natGateway, err := ec2.NewNatGateway(ctx, networkName+"-nat-gw", &ec2.NatGatewayArgs{
-		AllocationId: natGatewayEip.ID(),
-		SubnetId:     publicSubnet1a.ID(),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
+	flowLogsDestination := pulumi.String("").ToStringOutput()
+	if flowLogs != nil {
+		flowLogsDestination, err = newFlowLogs(ctx, networkName, flowLogs, vpc.ID(), &resource)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	igw, err := ec2.NewInternetGateway(ctx, networkName+"-igw", &ec2.InternetGatewayArgs{
+		VpcId: vpc.ID(),
 	}, pulumi.Parent(&resource))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating %s: %w", networkName+"-igw", err)
 	}
-	natGwRouteTable, err := ec2.NewRouteTable(ctx, networkName+"-nat-gateway-route-table", &ec2.RouteTableArgs{
+
+	publicRouteTable, err := ec2.NewRouteTable(ctx, networkName+"-public-route-table", &ec2.RouteTableArgs{
 		VpcId: vpc.ID(),
 		Routes: ec2.RouteTableRouteArray{
 			&ec2.RouteTableRouteArgs{
-				CidrBlock:    pulumi.String("0.0.0.0/0"),
-				NatGatewayId: natGateway.ID(),
+				CidrBlock: pulumi.String("0.0.0.0/0"),
+				GatewayId: igw.ID(),
 			},
 		},
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
 	}, pulumi.Parent(&resource))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating %s: %w", networkName+"-public-route-table", err)
 	}
 
-	_, err = ec2.NewRouteTableAssociation(ctx, networkName+"-private-subnet-1a-route-table-association", &ec2.RouteTableAssociationArgs{
-		SubnetId:     privateSubnet1a.ID(),
-		RouteTableId: natGwRouteTable.ID(),
-	}, pulumi.Parent(&resource))
-	if err != nil {
-		return nil, err
+	var publicSubnets []*ec2.Subnet
+	var publicSubnetIds pulumi.StringArray
+	for i, cidr := range publicCidrs {
+		subnetName := networkName + "-public-subnet-" + azSuffix(i)
+		publicSubnet, err := ec2.NewSubnet(ctx, subnetName, &ec2.SubnetArgs{
+			VpcId:               vpc.ID(),
+			CidrBlock:           pulumi.String(cidr),
+			MapPublicIpOnLaunch: pulumi.Bool(true),
+			AvailabilityZone:    pulumi.String(availabilityZones.Names[i]),
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", subnetName, err)
+		}
+
+		_, err = ec2.NewRouteTableAssociation(ctx, subnetName+"-route-table-association", &ec2.RouteTableAssociationArgs{
+			SubnetId:     publicSubnet.ID(),
+			RouteTableId: publicRouteTable.ID(),
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", subnetName+"-route-table-association", err)
+		}
+
+		publicSubnets = append(publicSubnets, publicSubnet)
+		publicSubnetIds = append(publicSubnetIds, publicSubnet.ID())
 	}
 
-	_, err = ec2.NewRouteTableAssociation(ctx, networkName+"-private-subnet-1b-route-table-association", &ec2.RouteTableAssociationArgs{
-		SubnetId:     privateSubnet1b.ID(),
-		RouteTableId: natGwRouteTable.ID(),
-	}, pulumi.Parent(&resource))
+	privateRouteTables := make([]*ec2.RouteTable, azCount)
+	switch natStrategy {
+	case NatStrategySingle:
+		natGatewayId, err := newNatGateway(ctx, networkName+"-nat-gw", publicSubnets[0].ID(), &resource)
+		if err != nil {
+			return nil, err
+		}
+		natGwRouteTable, err := newPrivateRouteTable(ctx, networkName+"-nat-gateway-route-table", vpc.ID(), natGatewayId, &resource)
+		if err != nil {
+			return nil, err
+		}
+		for i := range privateRouteTables {
+			privateRouteTables[i] = natGwRouteTable
+		}
+	case NatStrategyPerAz:
+		for i := range privateRouteTables {
+			natGatewayId, err := newNatGateway(ctx, networkName+"-nat-gw-"+azSuffix(i), publicSubnets[i].ID(), &resource)
+			if err != nil {
+				return nil, err
+			}
+			privateRouteTables[i], err = newPrivateRouteTable(ctx, networkName+"-private-route-table-"+azSuffix(i), vpc.ID(), natGatewayId, &resource)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case NatStrategyNone, NatStrategyIsolated:
+		privateRouteTable, err := newPrivateRouteTable(ctx, networkName+"-private-route-table", vpc.ID(), nil, &resource)
+		if err != nil {
+			return nil, err
+		}
+		for i := range privateRouteTables {
+			privateRouteTables[i] = privateRouteTable
+		}
+	}
+
+	var privateSubnetIds pulumi.StringArray
+	for i, cidr := range privateCidrs {
+		subnetName := networkName + "-private-subnet-" + azSuffix(i)
+		privateSubnet, err := ec2.NewSubnet(ctx, subnetName, &ec2.SubnetArgs{
+			VpcId:               vpc.ID(),
+			CidrBlock:           pulumi.String(cidr),
+			MapPublicIpOnLaunch: pulumi.Bool(false),
+			AvailabilityZone:    pulumi.String(availabilityZones.Names[i]),
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", subnetName, err)
+		}
+
+		_, err = ec2.NewRouteTableAssociation(ctx, subnetName+"-route-table-association", &ec2.RouteTableAssociationArgs{
+			SubnetId:     privateSubnet.ID(),
+			RouteTableId: privateRouteTables[i].ID(),
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", subnetName+"-route-table-association", err)
+		}
+
+		privateSubnetIds = append(privateSubnetIds, privateSubnet.ID())
+	}
+
+	var routeTableIds pulumi.StringArray
+	for i, routeTable := range privateRouteTables {
+		if i == 0 || routeTable != privateRouteTables[i-1] {
+			routeTableIds = append(routeTableIds, routeTable.ID())
+		}
+	}
+
+	var isolatedSubnetIds pulumi.StringArray
+	if len(isolatedCidrs) > 0 {
+		isolatedRouteTable, err := ec2.NewRouteTable(ctx, networkName+"-isolated-route-table", &ec2.RouteTableArgs{
+			VpcId: vpc.ID(),
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", networkName+"-isolated-route-table", err)
+		}
+
+		for i, cidr := range isolatedCidrs {
+			subnetName := networkName + "-isolated-subnet-" + azSuffix(i)
+			isolatedSubnet, err := ec2.NewSubnet(ctx, subnetName, &ec2.SubnetArgs{
+				VpcId:               vpc.ID(),
+				CidrBlock:           pulumi.String(cidr),
+				MapPublicIpOnLaunch: pulumi.Bool(false),
+				AvailabilityZone:    pulumi.String(availabilityZones.Names[i]),
+			}, pulumi.Parent(&resource))
+			if err != nil {
+				return nil, fmt.Errorf("creating %s: %w", subnetName, err)
+			}
+
+			_, err = ec2.NewRouteTableAssociation(ctx, subnetName+"-route-table-association", &ec2.RouteTableAssociationArgs{
+				SubnetId:     isolatedSubnet.ID(),
+				RouteTableId: isolatedRouteTable.ID(),
+			}, pulumi.Parent(&resource))
+			if err != nil {
+				return nil, fmt.Errorf("creating %s: %w", subnetName+"-route-table-association", err)
+			}
+
+			isolatedSubnetIds = append(isolatedSubnetIds, isolatedSubnet.ID())
+		}
+		routeTableIds = append(routeTableIds, isolatedRouteTable.ID())
+	}
+
+	err = newVpcEndpoints(ctx, networkName, vpcEndpoints, vpc.ID(), vpcRange, privateSubnetIds, routeTableIds, &resource)
 	if err != nil {
 		return nil, err
 	}
 
 	webUiLoadBalancerSecurityGroup, err := ec2.NewSecurityGroup(ctx, networkName+"-web-ui-loadbalancer-security-group", &ec2.SecurityGroupArgs{
 		VpcId: vpc.ID(),
-		Egress: ec2.SecurityGroupEgressArray{
-			ec2.SecurityGroupEgressArgs{
-				Protocol:   pulumi.String("-1"),
-				FromPort:   pulumi.Int(0),
-				ToPort:     pulumi.Int(0),
-				CidrBlocks: pulumi.StringArray{pulumi.String("0.0.0.0/0")},
-			},
-		},
-		Ingress: ec2.SecurityGroupIngressArray{
-			&ec2.SecurityGroupIngressArgs{
-				Description: pulumi.String("HTTPS"),
-				FromPort:    pulumi.Int(443),
-				ToPort:      pulumi.Int(443),
-				Protocol:    pulumi.String("tcp"),
-				CidrBlocks: pulumi.StringArray{
-					pulumi.String("0.0.0.0/0"),
-				},
-			},
-			&ec2.SecurityGroupIngressArgs{
-				Description: pulumi.String("HTTP"),
-				FromPort:    pulumi.Int(80),
-				ToPort:      pulumi.Int(80),
-				Protocol:    pulumi.String("tcp"),
-				CidrBlocks: pulumi.StringArray{
-					pulumi.String("0.0.0.0/0"),
-				},
-			},
-		},
 		Tags: &pulumi.StringMap{
-			"Name":            pulumi.String("elb allow http,https,egress"),
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
+			"Name":      pulumi.String("elb allow http,https,egress"),
+			ExposureTag: pulumi.String("public"),
 		},
 	}, pulumi.Parent(&resource))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating %s: %w", networkName+"-web-ui-loadbalancer-security-group", err)
 	}
 
 	webUiEc2InstanceSecurityGroup, err := ec2.NewSecurityGroup(ctx, networkName+"-web-ui-ec2-instance-security-group", &ec2.SecurityGroupArgs{
 		VpcId: vpc.ID(),
-		Ingress: ec2.SecurityGroupIngressArray{
-			&ec2.SecurityGroupIngressArgs{
-				Description: pulumi.String("http"),
-				FromPort:    pulumi.Int(5000),
-				ToPort:      pulumi.Int(5000),
-				Protocol:    pulumi.String("tcp"),
-				SecurityGroups: pulumi.StringArray{
-					webUiLoadBalancerSecurityGroup.ID(),
-				},
-			},
-		},
-		Egress: ec2.SecurityGroupEgressArray{
-			&ec2.SecurityGroupEgressArgs{
-				FromPort: pulumi.Int(0),
-				ToPort:   pulumi.Int(0),
-				Protocol: pulumi.String("-1"),
-				CidrBlocks: pulumi.StringArray{
-					pulumi.String("0.0.0.0/0"),
-				},
-			},
-		},
 		Tags: pulumi.StringMap{
-			"Name":            pulumi.String("allow web ui elb"),
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
+			"Name": pulumi.String("allow web ui elb"),
 		},
 	}, pulumi.Parent(&resource))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating %s: %w", networkName+"-web-ui-ec2-instance-security-group", err)
 	}
 
 	webApiLoadBalancerSecurityGroup, err := ec2.NewSecurityGroup(ctx, networkName+"-web-api-loadbalancer-security-group", &ec2.SecurityGroupArgs{
 		VpcId: vpc.ID(),
-		Egress: ec2.SecurityGroupEgressArray{
-			ec2.SecurityGroupEgressArgs{
-				Protocol:   pulumi.String("-1"),
-				FromPort:   pulumi.Int(0),
-				ToPort:     pulumi.Int(0),
-				CidrBlocks: pulumi.StringArray{pulumi.String("0.0.0.0/0")},
-			},
-		},
-		Ingress: ec2.SecurityGroupIngressArray{
-			&ec2.SecurityGroupIngressArgs{
-				Description: pulumi.String("HTTP"),
-				FromPort:    pulumi.Int(5000),
-				ToPort:      pulumi.Int(5000),
-				Protocol:    pulumi.String("tcp"),
-				SecurityGroups: pulumi.StringArray{
-					webUiEc2InstanceSecurityGroup.ID(),
-				},
-			},
-			&ec2.SecurityGroupIngressArgs{
-				Description: pulumi.String("tcp"),
-				FromPort:    pulumi.Int(5000),
-				ToPort:      pulumi.Int(5000),
-				Protocol:    pulumi.String("tcp"),
-				CidrBlocks: pulumi.StringArray{
-					pulumi.String("0.0.0.0/0"),
-				},
-			},
-		},
 		Tags: pulumi.StringMap{
-			"Name":            pulumi.String("elb web ui ec2"),
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
+			"Name": pulumi.String("elb web ui ec2"),
 		},
 	}, pulumi.Parent(&resource))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating %s: %w", networkName+"-web-api-loadbalancer-security-group", err)
 	}
 
 	webApiEc2InstanceSecurityGroup, err := ec2.NewSecurityGroup(ctx, networkName+"-web-api-ec2-instance-security-group", &ec2.SecurityGroupArgs{
 		VpcId: vpc.ID(),
-		Ingress: ec2.SecurityGroupIngressArray{
-			&ec2.SecurityGroupIngressArgs{
-				Description: pulumi.String("http"),
-				FromPort:    pulumi.Int(5000),
-				ToPort:      pulumi.Int(5000),
-				Protocol:    pulumi.String("tcp"),
-				SecurityGroups: pulumi.StringArray{
-					webApiLoadBalancerSecurityGroup.ID(),
-				},
-			},
-		},
-		Egress: ec2.SecurityGroupEgressArray{
-			&ec2.SecurityGroupEgressArgs{
-				FromPort: pulumi.Int(0),
-				ToPort:   pulumi.Int(0),
-				Protocol: pulumi.String("-1"),
-				CidrBlocks: pulumi.StringArray{
-					pulumi.String("0.0.0.0/0"),
-				},
-			},
-		},
 		Tags: pulumi.StringMap{
-			"Name":            pulumi.String("allow web api elb"),
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
+			"Name": pulumi.String("allow web api elb"),
 		},
 	}, pulumi.Parent(&resource))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating %s: %w", networkName+"-web-api-ec2-instance-security-group", err)
+	}
+
+	groupIds := map[string]pulumi.StringOutput{
+		WebUiLoadBalancerGroup:  webUiLoadBalancerSecurityGroup.ID().ToStringOutput(),
+		WebUiEc2InstanceGroup:   webUiEc2InstanceSecurityGroup.ID().ToStringOutput(),
+		WebApiLoadBalancerGroup: webApiLoadBalancerSecurityGroup.ID().ToStringOutput(),
+		WebApiEc2InstanceGroup:  webApiEc2InstanceSecurityGroup.ID().ToStringOutput(),
+	}
+	for _, group := range securityGroupNames {
+		rules := append(allowListRules(group, ingressAllowLists[group], groupPorts[group], groupIds), allowAllEgress)
+		_, err = NewSecurityGroupRules(ctx, networkName+"-"+group, groupIds[group], rules, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	resource.NetworkName = networkName
 	resource.VpcId = vpc.ID().ToStringOutput()
-	resource.PublicSubnet1aId = publicSubnet1a.ID().ToStringOutput()
-	resource.PublicSubnet1bId = publicSubnet1b.ID().ToStringOutput()
-	resource.PrivateSubnet1aId = privateSubnet1a.ID().ToStringOutput()
-	resource.PrivateSubnet1bId = privateSubnet1b.ID().ToStringOutput()
+	resource.PublicSubnetIds = publicSubnetIds.ToStringArrayOutput()
+	resource.PrivateSubnetIds = privateSubnetIds.ToStringArrayOutput()
+	resource.IsolatedSubnetIds = isolatedSubnetIds.ToStringArrayOutput()
 	resource.WebUiLoadBalancerSecurityGroupId = webUiLoadBalancerSecurityGroup.ID().ToStringOutput()
 	resource.WebUiEc2InstanceSecurityGroupId = webUiEc2InstanceSecurityGroup.ID().ToStringOutput()
 	resource.WebApiLoadBalancerSecurityGroupId = webApiLoadBalancerSecurityGroup.ID().ToStringOutput()
 	resource.WebApiEc2InstanceSecurityGroupId = webApiEc2InstanceSecurityGroup.ID().ToStringOutput()
+	resource.FlowLogsDestination = flowLogsDestination
 
 	ctx.RegisterResourceOutputs(&resource, pulumi.Map{
 		"VpcId":                             vpc.ID(),
-		"PrivateSubnet1aId":                 privateSubnet1a.ID(),
-		"PrivateSubnet1bId":                 privateSubnet1b.ID(),
-		"PublicSubnet1aId":                  publicSubnet1a.ID(),
-		"PublicSubnet1bId":                  publicSubnet1b.ID(),
+		"PublicSubnetIds":                   publicSubnetIds,
+		"PrivateSubnetIds":                  privateSubnetIds,
+		"IsolatedSubnetIds":                 isolatedSubnetIds,
 		"WebUiLoadBalancerSecurityGroupId":  webUiLoadBalancerSecurityGroup.ID(),
 		"WebUiEc2InstanceSecurityGroupId":   webUiEc2InstanceSecurityGroup.ID(),
 		"WebApiLoadBalancerSecurityGroupId": webApiLoadBalancerSecurityGroup.ID(),
 		"WebApiEc2InstanceSecurityGroupId":  webApiEc2InstanceSecurityGroup.ID(),
+		"FlowLogsDestination":               flowLogsDestination,
 	})
 
 	return &resource, nil
 }
+
+// newNatGateway creates a NAT gateway with its own elastic IP in the given
+// public subnet.
+func newNatGateway(ctx *pulumi.Context, name string, subnetId pulumi.StringInput, parent pulumi.Resource) (pulumi.IDOutput, error) {
+	natGatewayEip, err := ec2.NewEip(ctx, name+"-eip", &ec2.EipArgs{
+		Vpc: pulumi.Bool(true),
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return pulumi.IDOutput{}, fmt.Errorf("creating %s: %w", name+"-eip", err)
+	}
+
+	natGateway, err := ec2.NewNatGateway(ctx, name, &ec2.NatGatewayArgs{
+		AllocationId: natGatewayEip.ID(),
+		SubnetId:     subnetId,
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return pulumi.IDOutput{}, fmt.Errorf("creating %s: %w", name, err)
+	}
+
+	return natGateway.ID(), nil
+}
+
+// newPrivateRouteTable creates a route table for private subnets, with a
+// default route through natGatewayId when one is given.
+func newPrivateRouteTable(ctx *pulumi.Context, name string, vpcId pulumi.StringInput, natGatewayId pulumi.StringPtrInput, parent pulumi.Resource) (*ec2.RouteTable, error) {
+	var routes ec2.RouteTableRouteArray
+	if natGatewayId != nil {
+		routes = ec2.RouteTableRouteArray{
+			&ec2.RouteTableRouteArgs{
+				CidrBlock:    pulumi.String("0.0.0.0/0"),
+				NatGatewayId: natGatewayId,
+			},
+		}
+	}
+
+	routeTable, err := ec2.NewRouteTable(ctx, name, &ec2.RouteTableArgs{
+		VpcId:  vpcId,
+		Routes: routes,
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", name, err)
+	}
+	return routeTable, nil
+}
+
+// configIntOrDefault reads an integer network setting. Only an unset key
+// falls back, a value that isn't a number is an error.
+func configIntOrDefault(cfg *config.Config, key string, fallback int) (int, error) {
+	value, err := cfg.TryInt(key)
+	if errors.Is(err, config.ErrMissingVar) {
+		return fallback, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("network:%s must be a number: %w", key, err)
+	}
+	return value, nil
+}
+
+// azSuffix names subnets after their zone index, e.g. 1a, 1b, 1c.
+func azSuffix(index int) string {
+	return fmt.Sprintf("1%c", 'a'+index)
+}
@@ -0,0 +1,498 @@
+package core
+
+import (
+	"air-tek-iac/testutil"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ecs"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func runNetwork(t *testing.T, overrides map[string]string) (*testutil.Mocks, error) {
+	t.Helper()
+	config := testutil.DefaultConfig()
+	for key, value := range overrides {
+		config[key] = value
+	}
+	return testutil.Run(config, func(ctx *pulumi.Context) error {
+		_, err := NewNetwork(ctx)
+		return err
+	})
+}
+
+func TestNetworkSubnetPlacement(t *testing.T) {
+	mocks, err := runNetwork(t, map[string]string{"network:azCount": "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subnets := mocks.Resources("aws:ec2/subnet:Subnet")
+	if len(subnets) != 6 {
+		t.Fatalf("got %d subnets, want 6", len(subnets))
+	}
+
+	zones := map[string]map[string]bool{"public": {}, "private": {}}
+	for _, subnet := range subnets {
+		tier := "private"
+		if strings.Contains(subnet.Name, "-public-") {
+			tier = "public"
+		}
+		if subnet.BoolValue("mapPublicIpOnLaunch") != (tier == "public") {
+			t.Errorf("%s: mapPublicIpOnLaunch = %v", subnet.Name, subnet.BoolValue("mapPublicIpOnLaunch"))
+		}
+		zones[tier][subnet.StringValue("availabilityZone")] = true
+	}
+	for tier, seen := range zones {
+		if len(seen) != 3 {
+			t.Errorf("%s subnets span %d zones, want 3", tier, len(seen))
+		}
+	}
+}
+
+// TestNetworkKeepsSubnetLayout pins the subnets of the default config to the
+// ranges the network has always used. A changed CIDR replaces the subnet,
+// and the new one would overlap the live subnets.
+func TestNetworkKeepsSubnetLayout(t *testing.T) {
+	mocks, err := runNetwork(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"test-public-subnet-1a":  "10.1.1.0/24",
+		"test-public-subnet-1b":  "10.1.2.0/24",
+		"test-private-subnet-1a": "10.1.11.0/24",
+		"test-private-subnet-1b": "10.1.12.0/24",
+	}
+	for name, cidr := range want {
+		subnet := mocks.Resource(t, "aws:ec2/subnet:Subnet", name)
+		if got := subnet.StringValue("cidrBlock"); got != cidr {
+			t.Errorf("%s: cidrBlock = %s, want %s", name, got, cidr)
+		}
+	}
+}
+
+func TestNetworkNatStrategies(t *testing.T) {
+	tests := []struct {
+		strategy    string
+		natGateways int
+		routeTables int
+	}{
+		{"single", 1, 2},
+		{"per-az", 2, 3},
+		{"none", 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			mocks, err := runNetwork(t, map[string]string{"network:natStrategy": tt.strategy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(mocks.Resources("aws:ec2/natGateway:NatGateway")); got != tt.natGateways {
+				t.Errorf("got %d NAT gateways, want %d", got, tt.natGateways)
+			}
+			if got := len(mocks.Resources("aws:ec2/eip:Eip")); got != tt.natGateways {
+				t.Errorf("got %d elastic IPs, want %d", got, tt.natGateways)
+			}
+			if got := len(mocks.Resources("aws:ec2/routeTable:RouteTable")); got != tt.routeTables {
+				t.Errorf("got %d route tables, want %d", got, tt.routeTables)
+			}
+		})
+	}
+}
+
+func TestNetworkRejectsInvalidConfig(t *testing.T) {
+	tests := []map[string]string{
+		{"network:azCount": "7"},
+		{"network:azCount": "4"},
+		{"network:azCount": "three"},
+		{"network:natStrategy": "shared"},
+		{"network:vpcRange": "10.1.0.0/24"},
+		{"network:privateSubnetPrefixLength": "/20"},
+		{"network:publicSubnetPrefixLength": "twenty"},
+		{"network:isolatedSubnetPrefixLength": "/26"},
+	}
+	for _, overrides := range tests {
+		mocks, err := runNetwork(t, overrides)
+		if err == nil {
+			t.Errorf("%v: expected an error", overrides)
+		}
+		if vpcs := mocks.Resources("aws:ec2/vpc:Vpc"); len(vpcs) != 0 {
+			t.Errorf("%v: created a vpc before failing", overrides)
+		}
+	}
+}
+
+// TestNetworkReturnsCallFailures checks that NewNetwork returns failing
+// provider functions named after the network.
+func TestNetworkReturnsCallFailures(t *testing.T) {
+	tests := map[string]string{
+		"aws:index/getAvailabilityZones:getAvailabilityZones": "network test: getting availability zones: injected",
+		"aws:index/getRegion:getRegion":                       "network test: getting region: injected",
+	}
+	for token, want := range tests {
+		t.Run(token, func(t *testing.T) {
+			config := testutil.DefaultConfig()
+			config["network:vpcEndpoints"] = `["s3"]`
+			mocks := testutil.NewMocks()
+			mocks.Failures = map[string]error{token: errors.New("injected")}
+			var returned error
+			err := mocks.Run(config, func(ctx *pulumi.Context) error {
+				_, returned = NewNetwork(ctx)
+				return returned
+			})
+			if returned == nil || !strings.Contains(returned.Error(), want) {
+				t.Errorf("returned %v, want it to contain %q", returned, want)
+			}
+			if err == nil {
+				t.Error("expected the run to fail")
+			}
+		})
+	}
+}
+
+func TestNetworkTags(t *testing.T) {
+	config := testutil.DefaultConfig()
+	config["tags:owner"] = "platform-team"
+	config["tags:costCenter"] = "cc-1234"
+	mocks, err := testutil.Run(config, func(ctx *pulumi.Context) error {
+		if err := RegisterTagging(ctx, "test"); err != nil {
+			return err
+		}
+		_, err := NewNetwork(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		ProjectTag:     testutil.Project,
+		StackTag:       testutil.Stack,
+		NetworkTag:     "test",
+		EnvironmentTag: testutil.Stack,
+		OwnerTag:       "platform-team",
+		CostCenterTag:  "cc-1234",
+	}
+	for _, typeToken := range []string{"aws:ec2/vpc:Vpc", "aws:ec2/subnet:Subnet", "aws:ec2/eip:Eip", "aws:ec2/routeTable:RouteTable", "aws:ec2/securityGroup:SecurityGroup"} {
+		for _, r := range mocks.Resources(typeToken) {
+			tags := r.Tags()
+			for key, value := range want {
+				if tags[key] != value {
+					t.Errorf("%s %s has %s=%q, want %q", typeToken, r.Name, key, tags[key], value)
+				}
+			}
+		}
+	}
+
+	group := mocks.Resource(t, "aws:ec2/securityGroup:SecurityGroup", "test-web-ui-loadbalancer-security-group")
+	if name := group.Tags()["Name"]; name != "elb allow http,https,egress" {
+		t.Errorf("own Name tag = %q, want it kept", name)
+	}
+}
+
+func TestTaggingWithReferencedNetwork(t *testing.T) {
+	// An application stack gets its network from the platform stack and
+	// doesn't set network:name.
+	config := testutil.DefaultConfig()
+	delete(config, "network:name")
+	mocks, err := testutil.Run(config, func(ctx *pulumi.Context) error {
+		if _, err := ConfiguredNetworkName(ctx); err == nil {
+			t.Error("expected an error for the missing network:name")
+		}
+		if err := RegisterTagging(ctx, ""); err == nil {
+			t.Error("expected an error for an empty network name")
+		}
+		if err := RegisterTagging(ctx, "platform"); err != nil {
+			return err
+		}
+		_, err := ecs.NewCluster(ctx, "app", &ecs.ClusterArgs{})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := mocks.Resource(t, "aws:ecs/cluster:Cluster", "app")
+	if network := cluster.Tags()[NetworkTag]; network != "platform" {
+		t.Errorf("%s = %q, want the platform's network", NetworkTag, network)
+	}
+}
+
+const securityGroupRuleType = "aws:ec2/securityGroupRule:SecurityGroupRule"
+
+// ingressRulesOf returns the ingress rules registered for the group with the
+// given resource name.
+func ingressRulesOf(mocks *testutil.Mocks, group string) []testutil.Resource {
+	var rules []testutil.Resource
+	for _, rule := range mocks.Resources(securityGroupRuleType) {
+		if rule.StringValue("type") == "ingress" && rule.StringValue("securityGroupId") == group+"-id" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func TestNetworkInstanceSecurityGroupsAreNotWorldOpen(t *testing.T) {
+	mocks, err := runNetwork(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := 0
+	for _, group := range mocks.Resources("aws:ec2/securityGroup:SecurityGroup") {
+		if !strings.Contains(group.Name, "ec2-instance") {
+			continue
+		}
+		groups++
+		for _, rule := range ingressRulesOf(mocks, group.Name) {
+			for _, cidr := range rule.StringArray("cidrBlocks") {
+				if cidr == "0.0.0.0/0" {
+					t.Errorf("%s allows ingress from 0.0.0.0/0", group.Name)
+				}
+			}
+		}
+	}
+	if groups != 2 {
+		t.Errorf("checked %d instance security groups, want 2", groups)
+	}
+}
+
+func TestNetworkSecurityGroupRules(t *testing.T) {
+	mocks, err := runNetwork(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, group := range mocks.Resources("aws:ec2/securityGroup:SecurityGroup") {
+		if rules := group.Objects("ingress"); len(rules) != 0 {
+			t.Errorf("%s has %d inline ingress rules, want separate rule resources", group.Name, len(rules))
+		}
+	}
+
+	egress := mocks.Resource(t, securityGroupRuleType, "test-web-api-ec2-instance-egress-all")
+	if egress.StringValue("type") != "egress" || egress.StringValue("protocol") != "-1" {
+		t.Errorf("egress rule = %v", egress.Inputs)
+	}
+
+	https := mocks.Resource(t, securityGroupRuleType, "test-web-ui-loadbalancer-ingress-443-cidr")
+	if cidrs := https.StringArray("cidrBlocks"); len(cidrs) != 1 || cidrs[0] != "0.0.0.0/0" {
+		t.Errorf("web ui https cidr blocks = %v", cidrs)
+	}
+}
+
+func TestNetworkWebApiLoadBalancerOnlyAllowsUiTasks(t *testing.T) {
+	mocks, err := runNetwork(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := ingressRulesOf(mocks, "test-web-api-loadbalancer-security-group")
+	if len(rules) != 1 {
+		t.Fatalf("got %d ingress rules, want 1", len(rules))
+	}
+	if source := rules[0].StringValue("sourceSecurityGroupId"); source != "test-web-ui-ec2-instance-security-group-id" {
+		t.Errorf("source = %q, want only the web ui tasks", source)
+	}
+	if cidrs := rules[0].StringArray("cidrBlocks"); len(cidrs) != 0 {
+		t.Errorf("cidr blocks = %v, want none", cidrs)
+	}
+}
+
+func TestNetworkIngressAllowList(t *testing.T) {
+	mocks, err := runNetwork(t, map[string]string{
+		"network:ingress": `{
+			"web-api-loadbalancer": {"securityGroups": ["web-ui-ec2-instance", "sg-0123"], "cidrBlocks": ["10.20.0.0/16"], "prefixListIds": ["pl-1234"]},
+			"web-ui-ec2-instance": {"securityGroups": ["web-ui-loadbalancer", "web-api-ec2-instance", "web-ui-ec2-instance"]}
+		}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rules := ingressRulesOf(mocks, "test-web-api-loadbalancer-security-group"); len(rules) != 4 {
+		t.Errorf("got %d web api load balancer ingress rules, want 4", len(rules))
+	}
+	external := mocks.Resource(t, securityGroupRuleType, "test-web-api-loadbalancer-ingress-5000-from-sg-0123")
+	if source := external.StringValue("sourceSecurityGroupId"); source != "sg-0123" {
+		t.Errorf("source = %q", source)
+	}
+	cidr := mocks.Resource(t, securityGroupRuleType, "test-web-api-loadbalancer-ingress-5000-cidr")
+	if cidrs := cidr.StringArray("cidrBlocks"); len(cidrs) != 1 || cidrs[0] != "10.20.0.0/16" {
+		t.Errorf("cidr blocks = %v", cidrs)
+	}
+	prefixList := mocks.Resource(t, securityGroupRuleType, "test-web-api-loadbalancer-ingress-5000-prefix-list")
+	if prefixLists := prefixList.StringArray("prefixListIds"); len(prefixLists) != 1 {
+		t.Errorf("prefix lists = %v", prefixLists)
+	}
+
+	// Rules are separate resources, so a group can allow one created after it.
+	later := mocks.Resource(t, securityGroupRuleType, "test-web-ui-ec2-instance-ingress-5000-from-web-api-ec2-instance")
+	if source := later.StringValue("sourceSecurityGroupId"); source != "test-web-api-ec2-instance-security-group-id" {
+		t.Errorf("source = %q", source)
+	}
+	self := mocks.Resource(t, securityGroupRuleType, "test-web-ui-ec2-instance-ingress-5000-self")
+	if !self.BoolValue("self") {
+		t.Error("self rule should set self")
+	}
+}
+
+func TestNetworkRejectsInvalidIngress(t *testing.T) {
+	tests := map[string]string{
+		"world open internal group": `{"web-api-loadbalancer": {"cidrBlocks": ["0.0.0.0/0"]}}`,
+		"split world":               `{"web-api-loadbalancer": {"cidrBlocks": ["0.0.0.0/1", "128.0.0.0/1"]}}`,
+		"broad range":               `{"web-api-ec2-instance": {"cidrBlocks": ["10.0.0.0/7"]}}`,
+		"ipv6":                      `{"web-api-loadbalancer": {"cidrBlocks": ["2001:db8::/32"]}}`,
+		"ipv6 on a public group":    `{"web-ui-loadbalancer": {"cidrBlocks": ["0.0.0.0/0", "::/0"]}}`,
+		"unknown group":             `{"database": {"cidrBlocks": ["10.0.0.0/8"]}}`,
+		"unknown source":            `{"web-api-loadbalancer": {"securityGroups": ["database"]}}`,
+		"invalid cidr":              `{"web-api-loadbalancer": {"cidrBlocks": ["10.0.0.0/33"]}}`,
+		"no sources":                `{"web-api-loadbalancer": {}}`,
+	}
+	for name, ingress := range tests {
+		t.Run(name, func(t *testing.T) {
+			mocks, err := runNetwork(t, map[string]string{"network:ingress": ingress})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if vpcs := mocks.Resources("aws:ec2/vpc:Vpc"); len(vpcs) != 0 {
+				t.Error("the vpc was created before the ingress config was validated")
+			}
+		})
+	}
+}
+
+func TestNetworkVpcEndpoints(t *testing.T) {
+	mocks, err := runNetwork(t, map[string]string{
+		"network:natStrategy":  "per-az",
+		"network:vpcEndpoints": `["s3", "ecr.api"]`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpointType := "aws:ec2/vpcEndpoint:VpcEndpoint"
+	if endpoints := mocks.Resources(endpointType); len(endpoints) != 2 {
+		t.Fatalf("got %d endpoints, want 2", len(endpoints))
+	}
+
+	s3 := mocks.Resource(t, endpointType, "test-s3-endpoint")
+	if s3.StringValue("vpcEndpointType") != "Gateway" || s3.StringValue("serviceName") != "com.amazonaws.us-east-1.s3" {
+		t.Errorf("s3 endpoint = %v", s3.Inputs)
+	}
+	if routeTables := s3.StringArray("routeTableIds"); len(routeTables) != 2 {
+		t.Errorf("s3 endpoint route tables = %v, want one per zone", routeTables)
+	}
+
+	ecrApi := mocks.Resource(t, endpointType, "test-ecr-api-endpoint")
+	if ecrApi.StringValue("vpcEndpointType") != "Interface" || !ecrApi.BoolValue("privateDnsEnabled") {
+		t.Errorf("ecr.api endpoint = %v", ecrApi.Inputs)
+	}
+	if subnets := ecrApi.StringArray("subnetIds"); len(subnets) != 2 || !strings.Contains(subnets[0], "private") {
+		t.Errorf("ecr.api endpoint subnets = %v", subnets)
+	}
+	if groups := ecrApi.StringArray("securityGroupIds"); len(groups) != 1 || groups[0] != "test-vpc-endpoint-security-group-id" {
+		t.Errorf("ecr.api endpoint security groups = %v", groups)
+	}
+	https := mocks.Resource(t, securityGroupRuleType, "test-vpc-endpoint-ingress-443-vpc")
+	if cidrs := https.StringArray("cidrBlocks"); len(cidrs) != 1 || cidrs[0] != "10.1.0.0/16" {
+		t.Errorf("endpoint ingress cidr blocks = %v", cidrs)
+	}
+}
+
+func TestNetworkIsolated(t *testing.T) {
+	mocks, err := runNetwork(t, map[string]string{
+		"network:natStrategy":                "isolated",
+		"network:isolatedSubnetPrefixLength": "26",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gateways := mocks.Resources("aws:ec2/natGateway:NatGateway"); len(gateways) != 0 {
+		t.Errorf("got %d NAT gateways, want none", len(gateways))
+	}
+	if endpoints := mocks.Resources("aws:ec2/vpcEndpoint:VpcEndpoint"); len(endpoints) != 8 {
+		t.Errorf("got %d endpoints, want all 8", len(endpoints))
+	}
+	s3 := mocks.Resource(t, "aws:ec2/vpcEndpoint:VpcEndpoint", "test-s3-endpoint")
+	if routeTables := s3.StringArray("routeTableIds"); len(routeTables) != 2 {
+		t.Errorf("s3 endpoint route tables = %v, want the private and isolated tables", routeTables)
+	}
+}
+
+func TestNetworkRejectsUnknownVpcEndpoint(t *testing.T) {
+	_, err := runNetwork(t, map[string]string{"network:vpcEndpoints": `["ec2messages"]`})
+	if err == nil || !strings.Contains(err.Error(), "ec2messages") {
+		t.Errorf("err = %v, want the unsupported endpoint reported", err)
+	}
+}
+
+func TestNetworkFlowLogs(t *testing.T) {
+	t.Run("cloudwatch", func(t *testing.T) {
+		mocks, err := runNetwork(t, map[string]string{
+			"network:flowLogs": `{"destination": "cloudwatch", "trafficType": "REJECT", "retentionInDays": 14}`,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logGroup := mocks.Resource(t, "aws:cloudwatch/logGroup:LogGroup", "test-flow-logs")
+		if days := logGroup.Inputs["retentionInDays"].NumberValue(); days != 14 {
+			t.Errorf("retention = %v days, want 14", days)
+		}
+		flowLog := mocks.Resource(t, "aws:ec2/flowLog:FlowLog", "test-flow-log")
+		if flowLog.StringValue("logDestinationType") != "cloud-watch-logs" || flowLog.StringValue("trafficType") != "REJECT" {
+			t.Errorf("flow log = %v", flowLog.Inputs)
+		}
+		if flowLog.StringValue("vpcId") != "test-vpc-id" || !strings.Contains(flowLog.StringValue("iamRoleArn"), "test-flow-logs-role") {
+			t.Errorf("flow log = %v", flowLog.Inputs)
+		}
+		mocks.Resource(t, "aws:iam/rolePolicy:RolePolicy", "test-flow-logs-policy")
+	})
+
+	t.Run("s3", func(t *testing.T) {
+		mocks, err := runNetwork(t, map[string]string{"network:flowLogs": `{"destination": "s3"}`})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		flowLog := mocks.Resource(t, "aws:ec2/flowLog:FlowLog", "test-flow-log")
+		if flowLog.StringValue("logDestinationType") != "s3" || flowLog.StringValue("trafficType") != "ALL" {
+			t.Errorf("flow log = %v", flowLog.Inputs)
+		}
+		mocks.Resource(t, "aws:s3/bucketPublicAccessBlock:BucketPublicAccessBlock", "test-flow-logs-public-access-block")
+		lifecycle := mocks.Resource(t, "aws:s3/bucketLifecycleConfigurationV2:BucketLifecycleConfigurationV2", "test-flow-logs-lifecycle")
+		expiration := lifecycle.Objects("rules")[0]["expiration"].ObjectValue()
+		if days := expiration["days"].NumberValue(); days != 30 {
+			t.Errorf("objects expire after %v days, want the default 30", days)
+		}
+		if roles := mocks.Resources("aws:iam/role:Role"); len(roles) != 0 {
+			t.Errorf("S3 flow logs don't need a role, got %d", len(roles))
+		}
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		mocks, err := runNetwork(t, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flowLogs := mocks.Resources("aws:ec2/flowLog:FlowLog"); len(flowLogs) != 0 {
+			t.Errorf("got %d flow logs, want none", len(flowLogs))
+		}
+	})
+
+	for name, flowLogs := range map[string]string{
+		"unknown destination": `{"destination": "kinesis"}`,
+		"bad traffic type":    `{"destination": "s3", "trafficType": "DENIED"}`,
+		"bad retention":       `{"destination": "cloudwatch", "retentionInDays": 10}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := runNetwork(t, map[string]string{"network:flowLogs": flowLogs}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"air-tek-iac/utils"
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ecs"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Platform is the shared infrastructure the application services run on. It
+// is deployed from the platform project and read by application stacks
+// through a stack reference, so service changes never touch the network.
+type Platform struct {
+	NetworkName                       string
+	VpcId                             pulumi.StringOutput
+	PublicSubnetIds                   pulumi.StringArrayOutput
+	PrivateSubnetIds                  pulumi.StringArrayOutput
+	IsolatedSubnetIds                 pulumi.StringArrayOutput
+	WebUiEc2InstanceSecurityGroupId   pulumi.StringOutput
+	WebUiLoadBalancerSecurityGroupId  pulumi.StringOutput
+	WebApiEc2InstanceSecurityGroupId  pulumi.StringOutput
+	WebApiLoadBalancerSecurityGroupId pulumi.StringOutput
+	EcsClusterArn                     pulumi.StringOutput
+	FlowLogsDestination               pulumi.StringOutput
+}
+
+// NewPlatform creates the network, the ECS cluster and, when configured, the
+// account-wide ECR registry settings.
+func NewPlatform(ctx *pulumi.Context) (*Platform, error) {
+	registryArgs, err := utils.LoadECRRegistryArgs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	network, err := NewNetwork(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if registryArgs != nil {
+		_, err = utils.NewECRRegistry(ctx, network.NetworkName+"-ecr-registry", registryArgs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ecsCluster, err := ecs.NewCluster(ctx, network.NetworkName+"-ecs-cluster", nil)
+	if err != nil {
+		return nil, fmt.Errorf("platform %s: creating %s: %w", network.NetworkName, network.NetworkName+"-ecs-cluster", err)
+	}
+
+	return &Platform{
+		NetworkName:                       network.NetworkName,
+		VpcId:                             network.VpcId,
+		PublicSubnetIds:                   network.PublicSubnetIds,
+		PrivateSubnetIds:                  network.PrivateSubnetIds,
+		IsolatedSubnetIds:                 network.IsolatedSubnetIds,
+		WebUiEc2InstanceSecurityGroupId:   network.WebUiEc2InstanceSecurityGroupId,
+		WebUiLoadBalancerSecurityGroupId:  network.WebUiLoadBalancerSecurityGroupId,
+		WebApiEc2InstanceSecurityGroupId:  network.WebApiEc2InstanceSecurityGroupId,
+		WebApiLoadBalancerSecurityGroupId: network.WebApiLoadBalancerSecurityGroupId,
+		EcsClusterArn:                     ecsCluster.Arn,
+		FlowLogsDestination:               network.FlowLogsDestination,
+	}, nil
+}
+
+// Export publishes the platform as stack outputs for GetPlatformReference.
+func (p *Platform) Export(ctx *pulumi.Context) {
+	ctx.Export("networkName", pulumi.String(p.NetworkName))
+	ctx.Export("vpcId", p.VpcId)
+	ctx.Export("publicSubnetIds", p.PublicSubnetIds)
+	ctx.Export("privateSubnetIds", p.PrivateSubnetIds)
+	ctx.Export("isolatedSubnetIds", p.IsolatedSubnetIds)
+	ctx.Export("webUiEc2InstanceSecurityGroupId", p.WebUiEc2InstanceSecurityGroupId)
+	ctx.Export("webUiLoadBalancerSecurityGroupId", p.WebUiLoadBalancerSecurityGroupId)
+	ctx.Export("webApiEc2InstanceSecurityGroupId", p.WebApiEc2InstanceSecurityGroupId)
+	ctx.Export("webApiLoadBalancerSecurityGroupId", p.WebApiLoadBalancerSecurityGroupId)
+	ctx.Export("ecsClusterArn", p.EcsClusterArn)
+	ctx.Export("flowLogsDestination", p.FlowLogsDestination)
+}
+
+// GetPlatformReference reads a platform exported by another stack, given as
+// <organization>/<project>/<stack>.
+func GetPlatformReference(ctx *pulumi.Context, stackName string) (*Platform, error) {
+	ref, err := pulumi.NewStackReference(ctx, stackName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("platform stack %s: %w", stackName, err)
+	}
+
+	networkName, err := ref.GetOutputDetails("networkName")
+	if err != nil {
+		return nil, fmt.Errorf("platform stack %s: reading networkName: %w", stackName, err)
+	}
+	name, ok := networkName.Value.(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("platform stack %s does not export a networkName", stackName)
+	}
+
+	return &Platform{
+		NetworkName:                       name,
+		VpcId:                             ref.GetStringOutput(pulumi.String("vpcId")),
+		PublicSubnetIds:                   getStringArrayOutput(ref, "publicSubnetIds"),
+		PrivateSubnetIds:                  getStringArrayOutput(ref, "privateSubnetIds"),
+		IsolatedSubnetIds:                 getStringArrayOutput(ref, "isolatedSubnetIds"),
+		WebUiEc2InstanceSecurityGroupId:   ref.GetStringOutput(pulumi.String("webUiEc2InstanceSecurityGroupId")),
+		WebUiLoadBalancerSecurityGroupId:  ref.GetStringOutput(pulumi.String("webUiLoadBalancerSecurityGroupId")),
+		WebApiEc2InstanceSecurityGroupId:  ref.GetStringOutput(pulumi.String("webApiEc2InstanceSecurityGroupId")),
+		WebApiLoadBalancerSecurityGroupId: ref.GetStringOutput(pulumi.String("webApiLoadBalancerSecurityGroupId")),
+		EcsClusterArn:                     ref.GetStringOutput(pulumi.String("ecsClusterArn")),
+		FlowLogsDestination:               ref.GetStringOutput(pulumi.String("flowLogsDestination")),
+	}, nil
+}
+
+func getStringArrayOutput(ref *pulumi.StackReference, name string) pulumi.StringArrayOutput {
+	return ref.GetOutput(pulumi.String(name)).ApplyT(func(value interface{}) ([]string, error) {
+		if value == nil {
+			return nil, nil
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("stack output %s is not a list", name)
+		}
+		ids := make([]string, len(items))
+		for i, item := range items {
+			id, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("stack output %s[%d] is not a string", name, i)
+			}
+			ids[i] = id
+		}
+		return ids, nil
+	}).(pulumi.StringArrayOutput)
+}
+
+// ServiceSecurityGroups returns the load balancer and task security groups
+// the network keeps for the named service group.
+func (p *Platform) ServiceSecurityGroups(name string) (loadBalancer pulumi.StringOutput, task pulumi.StringOutput, err error) {
+	switch name {
+	case "web-ui":
+		return p.WebUiLoadBalancerSecurityGroupId, p.WebUiEc2InstanceSecurityGroupId, nil
+	case "web-api":
+		return p.WebApiLoadBalancerSecurityGroupId, p.WebApiEc2InstanceSecurityGroupId, nil
+	}
+	return pulumi.StringOutput{}, pulumi.StringOutput{}, fmt.Errorf("the network has no security groups for %q, expected web-ui or web-api", name)
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type RuleDirection string
+
+const (
+	IngressRule RuleDirection = "ingress"
+	EgressRule  RuleDirection = "egress"
+)
+
+// SecurityGroupRule declares one rule of a security group. Each rule becomes
+// its own ec2.SecurityGroupRule, so rules can be added to a shared group, for
+// example one owned by the platform stack, without a diff on the group.
+type SecurityGroupRule struct {
+	// Name identifies the rule within its group and ends up in the resource
+	// name, so it has to stay the same for the rule to be kept in place.
+	Name      string
+	Direction RuleDirection
+	// Protocol is tcp, udp, icmp or -1 for all traffic. Defaults to tcp.
+	Protocol string
+	FromPort int
+	ToPort   int
+	// Exactly one of SecurityGroupId, CidrBlocks, PrefixListIds and Self is
+	// the source of an ingress rule or the destination of an egress rule.
+	SecurityGroupId pulumi.StringInput
+	CidrBlocks      []string
+	PrefixListIds   []string
+	Self            bool
+	Description     string
+}
+
+func (r *SecurityGroupRule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("security group rule needs a name")
+	}
+	if r.Direction != IngressRule && r.Direction != EgressRule {
+		return fmt.Errorf("security group rule %s: direction must be %q or %q, got %q", r.Name, IngressRule, EgressRule, r.Direction)
+	}
+	if r.Protocol != "-1" && (r.FromPort < 0 || r.ToPort < r.FromPort || r.ToPort > 65535) {
+		return fmt.Errorf("security group rule %s: invalid port range %d-%d", r.Name, r.FromPort, r.ToPort)
+	}
+
+	targets := 0
+	if r.SecurityGroupId != nil {
+		targets++
+	}
+	if len(r.CidrBlocks) > 0 {
+		targets++
+	}
+	if len(r.PrefixListIds) > 0 {
+		targets++
+	}
+	if r.Self {
+		targets++
+	}
+	if targets != 1 {
+		return fmt.Errorf("security group rule %s: needs exactly one of a security group, cidr blocks, prefix lists or self", r.Name)
+	}
+	return nil
+}
+
+// NewSecurityGroupRules creates the rules on the given security group, naming
+// each resource <name>-<rule name>. All rules are validated before any
+// resource is created.
+func NewSecurityGroupRules(ctx *pulumi.Context, name string, securityGroupId pulumi.StringInput, rules []SecurityGroupRule, opts ...pulumi.ResourceOption) ([]*ec2.SecurityGroupRule, error) {
+	names := map[string]bool{}
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if names[rules[i].Name] {
+			return nil, fmt.Errorf("%s: security group rule %s is defined more than once", name, rules[i].Name)
+		}
+		names[rules[i].Name] = true
+	}
+
+	created := make([]*ec2.SecurityGroupRule, 0, len(rules))
+	for _, rule := range rules {
+		protocol := rule.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		args := &ec2.SecurityGroupRuleArgs{
+			SecurityGroupId: securityGroupId,
+			Type:            pulumi.String(string(rule.Direction)),
+			Protocol:        pulumi.String(protocol),
+			FromPort:        pulumi.Int(rule.FromPort),
+			ToPort:          pulumi.Int(rule.ToPort),
+		}
+		if rule.Description != "" {
+			args.Description = pulumi.String(rule.Description)
+		}
+		switch {
+		case rule.SecurityGroupId != nil:
+			args.SourceSecurityGroupId = rule.SecurityGroupId
+		case len(rule.CidrBlocks) > 0:
+			args.CidrBlocks = pulumi.ToStringArray(rule.CidrBlocks)
+		case len(rule.PrefixListIds) > 0:
+			args.PrefixListIds = pulumi.ToStringArray(rule.PrefixListIds)
+		case rule.Self:
+			args.Self = pulumi.Bool(true)
+		}
+
+		securityGroupRule, err := ec2.NewSecurityGroupRule(ctx, name+"-"+rule.Name, args, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", name+"-"+rule.Name, err)
+		}
+		created = append(created, securityGroupRule)
+	}
+
+	return created, nil
+}
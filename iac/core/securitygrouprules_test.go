@@ -0,0 +1,56 @@
+package core
+
+import (
+	"air-tek-iac/testutil"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestNewSecurityGroupRules(t *testing.T) {
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewSecurityGroupRules(ctx, "shared", pulumi.String("sg-shared"), []SecurityGroupRule{
+			{Name: "metrics", Direction: IngressRule, FromPort: 9090, ToPort: 9090, SecurityGroupId: pulumi.String("sg-prometheus"), Description: "metrics"},
+			{Name: "dns", Direction: EgressRule, Protocol: "udp", FromPort: 53, ToPort: 53, CidrBlocks: []string{"10.1.0.2/32"}},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := mocks.Resource(t, "aws:ec2/securityGroupRule:SecurityGroupRule", "shared-metrics")
+	if metrics.StringValue("type") != "ingress" || metrics.StringValue("protocol") != "tcp" ||
+		metrics.StringValue("securityGroupId") != "sg-shared" || metrics.StringValue("sourceSecurityGroupId") != "sg-prometheus" {
+		t.Errorf("metrics rule = %v", metrics.Inputs)
+	}
+	dns := mocks.Resource(t, "aws:ec2/securityGroupRule:SecurityGroupRule", "shared-dns")
+	if dns.StringValue("type") != "egress" || dns.StringValue("protocol") != "udp" {
+		t.Errorf("dns rule = %v", dns.Inputs)
+	}
+}
+
+func TestNewSecurityGroupRulesValidation(t *testing.T) {
+	tests := map[string][]SecurityGroupRule{
+		"missing name":   {{Direction: IngressRule, FromPort: 80, ToPort: 80, Self: true}},
+		"bad direction":  {{Name: "a", Direction: "inbound", FromPort: 80, ToPort: 80, Self: true}},
+		"bad port range": {{Name: "a", Direction: IngressRule, FromPort: 90, ToPort: 80, Self: true}},
+		"no source":      {{Name: "a", Direction: IngressRule, FromPort: 80, ToPort: 80}},
+		"two sources":    {{Name: "a", Direction: IngressRule, FromPort: 80, ToPort: 80, Self: true, CidrBlocks: []string{"10.0.0.0/8"}}},
+		"duplicate name": {{Name: "a", Direction: IngressRule, FromPort: 80, ToPort: 80, Self: true}, {Name: "a", Direction: EgressRule, Protocol: "-1", Self: true}},
+	}
+	for name, rules := range tests {
+		t.Run(name, func(t *testing.T) {
+			mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+				_, err := NewSecurityGroupRules(ctx, "shared", pulumi.String("sg-shared"), rules)
+				return err
+			})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if created := mocks.Resources("aws:ec2/securityGroupRule:SecurityGroupRule"); len(created) != 0 {
+				t.Errorf("%d rules were created before validation failed", len(created))
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Names of the network's security groups, as used in network:ingress.
+const (
+	WebUiLoadBalancerGroup  = "web-ui-loadbalancer"
+	WebUiEc2InstanceGroup   = "web-ui-ec2-instance"
+	WebApiLoadBalancerGroup = "web-api-loadbalancer"
+	WebApiEc2InstanceGroup  = "web-api-ec2-instance"
+)
+
+var securityGroupNames = []string{WebUiLoadBalancerGroup, WebUiEc2InstanceGroup, WebApiLoadBalancerGroup, WebApiEc2InstanceGroup}
+
+// publicSecurityGroups may allow ingress from anywhere.
+var publicSecurityGroups = map[string]bool{WebUiLoadBalancerGroup: true}
+
+// minPrivatePrefixBits is the widest range a group that isn't public may
+// allow, so a few broad ranges can't add up to the whole internet.
+const minPrivatePrefixBits = 8
+
+// IngressAllowList lists the sources that may reach a security group on its
+// service ports. SecurityGroups holds security group ids or the names of the
+// network's own groups.
+type IngressAllowList struct {
+	SecurityGroups []string `json:"securityGroups"`
+	CidrBlocks     []string `json:"cidrBlocks"`
+	PrefixListIds  []string `json:"prefixListIds"`
+}
+
+// defaultIngressAllowLists only open the web ui load balancer to the world,
+// every other group is reachable from the group in front of it.
+func defaultIngressAllowLists() map[string]IngressAllowList {
+	return map[string]IngressAllowList{
+		WebUiLoadBalancerGroup:  {CidrBlocks: []string{"0.0.0.0/0"}},
+		WebUiEc2InstanceGroup:   {SecurityGroups: []string{WebUiLoadBalancerGroup}},
+		WebApiLoadBalancerGroup: {SecurityGroups: []string{WebUiEc2InstanceGroup}},
+		WebApiEc2InstanceGroup:  {SecurityGroups: []string{WebApiLoadBalancerGroup}},
+	}
+}
+
+// loadIngressAllowLists reads network:ingress, where an entry replaces the
+// default allow-list of that group, and validates the result.
+func loadIngressAllowLists(cfg *config.Config) (map[string]IngressAllowList, error) {
+	allowLists := defaultIngressAllowLists()
+
+	var configured map[string]IngressAllowList
+	if err := cfg.TryObject("ingress", &configured); err != nil && !errors.Is(err, config.ErrMissingVar) {
+		return nil, fmt.Errorf("network:ingress: %w", err)
+	}
+	for group, allowList := range configured {
+		if _, ok := allowLists[group]; !ok {
+			return nil, fmt.Errorf("network:ingress: unknown security group %q, expected one of %s", group, strings.Join(securityGroupNames, ", "))
+		}
+		allowLists[group] = allowList
+	}
+
+	for _, group := range securityGroupNames {
+		if err := allowLists[group].validate(group); err != nil {
+			return nil, fmt.Errorf("network:ingress: %w", err)
+		}
+	}
+	return allowLists, nil
+}
+
+func (a IngressAllowList) validate(group string) error {
+	if len(a.SecurityGroups) == 0 && len(a.CidrBlocks) == 0 && len(a.PrefixListIds) == 0 {
+		return fmt.Errorf("%s allows no sources, nothing could reach it", group)
+	}
+	for _, source := range a.SecurityGroups {
+		if strings.HasPrefix(source, "sg-") || source == group {
+			continue
+		}
+		if indexOf(securityGroupNames, source) < 0 {
+			return fmt.Errorf("%s allows unknown security group %q", group, source)
+		}
+	}
+	for _, cidr := range a.CidrBlocks {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("%s allows invalid cidr block %q", group, cidr)
+		}
+		// The network has no IPv6 ranges, and rules take IPv6 sources in
+		// a field of their own.
+		if !prefix.Addr().Is4() {
+			return fmt.Errorf("%s allows ipv6 cidr block %q, only ipv4 is supported", group, cidr)
+		}
+		if prefix.Bits() < minPrivatePrefixBits && !publicSecurityGroups[group] {
+			return fmt.Errorf("%s is not public and can't allow %s, ranges can be at most a /%d", group, cidr, minPrivatePrefixBits)
+		}
+	}
+	return nil
+}
+
+// ingressPort is one port a security group serves on.
+type ingressPort struct {
+	description string
+	port        int
+}
+
+// groupPorts lists the ports each of the network's groups serves on.
+var groupPorts = map[string][]ingressPort{
+	WebUiLoadBalancerGroup:  {{description: "HTTPS", port: 443}, {description: "HTTP", port: 80}},
+	WebUiEc2InstanceGroup:   {{description: "http", port: 5000}},
+	WebApiLoadBalancerGroup: {{description: "HTTP", port: 5000}},
+	WebApiEc2InstanceGroup:  {{description: "http", port: 5000}},
+}
+
+// ServiceLoadBalancerPorts returns the ports the network's load balancer
+// group for the web-ui or web-api service accepts traffic on, and false for
+// other services.
+func ServiceLoadBalancerPorts(name string) ([]int, bool) {
+	ports, ok := groupPorts[name+"-loadbalancer"]
+	if !ok {
+		return nil, false
+	}
+	var numbers []int
+	for _, port := range ports {
+		numbers = append(numbers, port.port)
+	}
+	return numbers, true
+}
+
+// allowListRules allows every source of allowList on each of the ports.
+// groupIds holds the ids of the network's groups by name.
+func allowListRules(group string, allowList IngressAllowList, ports []ingressPort, groupIds map[string]pulumi.StringOutput) []SecurityGroupRule {
+	var rules []SecurityGroupRule
+	for _, port := range ports {
+		rule := SecurityGroupRule{
+			Direction:   IngressRule,
+			Protocol:    "tcp",
+			FromPort:    port.port,
+			ToPort:      port.port,
+			Description: port.description,
+		}
+		prefix := fmt.Sprintf("ingress-%d", port.port)
+
+		for _, source := range allowList.SecurityGroups {
+			sourceRule := rule
+			switch {
+			case source == group:
+				sourceRule.Name = prefix + "-self"
+				sourceRule.Self = true
+			case strings.HasPrefix(source, "sg-"):
+				sourceRule.Name = prefix + "-from-" + source
+				sourceRule.SecurityGroupId = pulumi.String(source)
+			default:
+				sourceRule.Name = prefix + "-from-" + source
+				sourceRule.SecurityGroupId = groupIds[source]
+			}
+			rules = append(rules, sourceRule)
+		}
+		if len(allowList.CidrBlocks) > 0 {
+			cidrRule := rule
+			cidrRule.Name = prefix + "-cidr"
+			cidrRule.CidrBlocks = allowList.CidrBlocks
+			rules = append(rules, cidrRule)
+		}
+		if len(allowList.PrefixListIds) > 0 {
+			prefixListRule := rule
+			prefixListRule.Name = prefix + "-prefix-list"
+			prefixListRule.PrefixListIds = allowList.PrefixListIds
+			rules = append(rules, prefixListRule)
+		}
+	}
+	return rules
+}
+
+// allowAllEgress lets a group reach anything, like the default AWS rule.
+var allowAllEgress = SecurityGroupRule{
+	Name:       "egress-all",
+	Direction:  EgressRule,
+	Protocol:   "-1",
+	CidrBlocks: []string{"0.0.0.0/0"},
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Tag keys applied to every taggable resource.
+const (
+	ProjectTag     = "air-tek:project"
+	StackTag       = "air-tek:stack"
+	NetworkTag     = "air-tek:network"
+	EnvironmentTag = "air-tek:environment"
+	OwnerTag       = "air-tek:owner"
+	CostCenterTag  = "air-tek:cost-center"
+)
+
+// ExposureTag marks a security group that is meant to be reachable from the
+// internet, with the value public.
+const ExposureTag = "air-tek:exposure"
+
+// StandardTags returns the tags every taggable resource in the stack gets:
+// project, stack and network, plus environment, owner and cost center from
+// the tags config namespace. The environment defaults to the stack name.
+func StandardTags(ctx *pulumi.Context, networkName string) map[string]string {
+	config := config.New(ctx, "tags")
+
+	tags := map[string]string{
+		ProjectTag:     ctx.Project(),
+		StackTag:       ctx.Stack(),
+		NetworkTag:     networkName,
+		EnvironmentTag: ctx.Stack(),
+	}
+	if environment := config.Get("environment"); environment != "" {
+		tags[EnvironmentTag] = environment
+	}
+	if owner := config.Get("owner"); owner != "" {
+		tags[OwnerTag] = owner
+	}
+	if costCenter := config.Get("costCenter"); costCenter != "" {
+		tags[CostCenterTag] = costCenter
+	}
+	return tags
+}
+
+// RegisterTagging adds a stack transformation that merges StandardTags into
+// every resource with a tags map, so components never repeat them. It has to
+// run before any taggable resource is created. Tags a resource sets itself,
+// like Name, take precedence. networkName is ConfiguredNetworkName in stacks
+// that create the network and the platform's NetworkName in stacks that
+// reference it.
+func RegisterTagging(ctx *pulumi.Context, networkName string) error {
+	if networkName == "" {
+		return errors.New("tagging needs the network name")
+	}
+	tags := StandardTags(ctx, networkName)
+
+	return ctx.RegisterStackTransformation(func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+		if !applyTags(args.Props, tags) {
+			return nil
+		}
+		return &pulumi.ResourceTransformationResult{Props: args.Props, Opts: args.Opts}
+	})
+}
+
+// ConfiguredNetworkName reads network:name, the name of the network the stack
+// creates.
+func ConfiguredNetworkName(ctx *pulumi.Context) (string, error) {
+	name, err := config.New(ctx, "network").Try("name")
+	if err != nil {
+		return "", fmt.Errorf("network:name is required to create the network: %w", err)
+	}
+	return name, nil
+}
+
+var stringMapInputType = reflect.TypeOf((*pulumi.StringMapInput)(nil)).Elem()
+
+// IsTaggable reports whether props are resource args with a tags map.
+func IsTaggable(props pulumi.Input) bool {
+	_, ok := tagsField(props)
+	return ok
+}
+
+// applyTags merges tags into the Tags field of props, reporting whether props
+// had one.
+func applyTags(props pulumi.Input, tags map[string]string) bool {
+	field, ok := tagsField(props)
+	if !ok {
+		return false
+	}
+
+	merged := pulumi.StringMap{}
+	for key, value := range tags {
+		merged[key] = pulumi.String(value)
+	}
+
+	var result pulumi.StringMapInput = merged
+	switch existing := field.Interface().(type) {
+	case nil:
+	case pulumi.StringMap:
+		for key, value := range existing {
+			merged[key] = value
+		}
+	case *pulumi.StringMap:
+		for key, value := range *existing {
+			merged[key] = value
+		}
+	case pulumi.StringMapInput:
+		result = existing.ToStringMapOutput().ApplyT(func(own map[string]string) map[string]string {
+			combined := make(map[string]string, len(tags)+len(own))
+			for key, value := range tags {
+				combined[key] = value
+			}
+			for key, value := range own {
+				combined[key] = value
+			}
+			return combined
+		}).(pulumi.StringMapOutput)
+	}
+
+	field.Set(reflect.ValueOf(&result).Elem())
+	return true
+}
+
+func tagsField(props pulumi.Input) (reflect.Value, bool) {
+	value := reflect.ValueOf(props)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	field := value.Elem().FieldByName("Tags")
+	if !field.IsValid() || field.Type() != stringMapInputType || !field.CanSet() {
+		return reflect.Value{}, false
+	}
+	return field, true
+}
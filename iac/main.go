@@ -1,71 +1,56 @@
 package main
 
 import (
-	"air-tek-iac/api"
 	"air-tek-iac/core"
-	"air-tek-iac/ui"
+	"air-tek-iac/services"
 
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ecs"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
 )
 
 func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
-		network, err := core.NewNetwork(ctx)
-
+		specs, loadBalancers, err := services.Load(ctx)
 		if err != nil {
 			return err
 		}
 
-		ecsCluster, err := ecs.NewCluster(ctx, network.NetworkName+"-ecs-cluster", nil)
+		// Tagging starts once the network name is known, from the platform
+		// stack or from network:name, and before any taggable resource.
+		var platform *core.Platform
+		if platformStack := config.New(ctx, "platform").Get("stack"); platformStack != "" {
+			platform, err = core.GetPlatformReference(ctx, platformStack)
+			if err != nil {
+				return err
+			}
+			if err := core.RegisterTagging(ctx, platform.NetworkName); err != nil {
+				return err
+			}
+		} else {
+			networkName, err := core.ConfiguredNetworkName(ctx)
+			if err != nil {
+				return err
+			}
+			if err := core.RegisterTagging(ctx, networkName); err != nil {
+				return err
+			}
+			platform, err = core.NewPlatform(ctx)
+			if err != nil {
+				return err
+			}
+			ctx.Export("flowLogsDestination", platform.FlowLogsDestination)
+		}
+
+		deployed, err := services.Deploy(ctx, platform, specs, loadBalancers)
 		if err != nil {
 			return err
 		}
 
-		webApi, err := api.NewWebApi(ctx, &api.WebApiArgs{
-			NetworkName: network.NetworkName,
-			VpcId:       network.VpcId,
-			LoadBalancerSubnets: pulumi.StringArray{
-				network.PrivateSubnet1aId,
-				network.PrivateSubnet1bId,
-			},
-			LoadBalancerSecurityGroups: pulumi.StringArray{
-				network.WebApiLoadBalancerSecurityGroupId,
-			},
-			Ec2Subnets: pulumi.StringArray{
-				network.PrivateSubnet1aId,
-				network.PrivateSubnet1bId,
-			},
-			Ec2SecurityGroups: pulumi.StringArray{
-				network.WebApiEc2InstanceSecurityGroupId,
-			},
-			EcsClusterArn: ecsCluster.Arn,
-		})
-
-		webUi, err := ui.NewWebUi(ctx, &ui.WebUiArgs{
-			NetworkName: network.NetworkName,
-			VpcId:       network.VpcId,
-			LoadBalancerSubnets: pulumi.StringArray{
-				network.PublicSubnet1aId,
-				network.PublicSubnet1bId,
-			},
-			LoadBalancerSecurityGroups: pulumi.StringArray{
-				network.WebUiLoadBalancerSecurityGroupId,
-			},
-			Ec2Subnets: pulumi.StringArray{
-				network.PrivateSubnet1aId,
-				network.PrivateSubnet1bId,
-			},
-			Ec2SecurityGroups: pulumi.StringArray{
-				network.WebUiEc2InstanceSecurityGroupId,
-			},
-			EcsClusterArn:  ecsCluster.Arn,
-			WebApiEndpoint: webApi.ApiEndpoint,
-		})
-
-		ctx.Export("vpcId", network.VpcId)
-		ctx.Export("web-api-url", webApi.Url)
-		ctx.Export("web-ui-url", webUi.Url)
+		ctx.Export("vpcId", platform.VpcId)
+		for _, spec := range specs {
+			ctx.Export(spec.Name+"-url", deployed[spec.Name].Url)
+			ctx.Export(spec.Name+"-log-group", deployed[spec.Name].LogGroupName)
+		}
 
 		return nil
 	})
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"air-tek-iac/core"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func main() {
+	pulumi.Run(func(ctx *pulumi.Context) error {
+		networkName, err := core.ConfiguredNetworkName(ctx)
+		if err != nil {
+			return err
+		}
+		if err := core.RegisterTagging(ctx, networkName); err != nil {
+			return err
+		}
+
+		platform, err := core.NewPlatform(ctx)
+		if err != nil {
+			return err
+		}
+
+		platform.Export(ctx)
+
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+package policy
+
+import (
+	"context"
+
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Analyzer serves a policy pack to the Pulumi engine over the analyzer
+// protocol, the way the CLI runs policy packs.
+type Analyzer struct {
+	pulumirpc.UnimplementedAnalyzerServer
+
+	pack  *PolicyPack
+	stack string
+}
+
+func NewAnalyzer(pack *PolicyPack, stack string) *Analyzer {
+	return &Analyzer{pack: pack, stack: stack}
+}
+
+// Analyze is called for every resource before it is created or updated. The
+// policies look up the resources a resource refers to, which are only all
+// known at the end, so they run in AnalyzeStack.
+func (a *Analyzer) Analyze(ctx context.Context, req *pulumirpc.AnalyzeRequest) (*pulumirpc.AnalyzeResponse, error) {
+	return &pulumirpc.AnalyzeResponse{}, nil
+}
+
+// AnalyzeStack runs every policy against every resource of the stack at the
+// end of a preview or update.
+func (a *Analyzer) AnalyzeStack(ctx context.Context, req *pulumirpc.AnalyzeStackRequest) (*pulumirpc.AnalyzeResponse, error) {
+	resources := make([]Resource, 0, len(req.GetResources()))
+	for _, r := range req.GetResources() {
+		properties, _ := revealSecrets(r.GetProperties().AsMap()).(map[string]interface{})
+		dependencies := map[string][]string{}
+		for key, urns := range r.GetPropertyDependencies() {
+			dependencies[key] = urns.GetUrns()
+		}
+		resources = append(resources, Resource{
+			URN:                  r.GetUrn(),
+			Type:                 r.GetType(),
+			Name:                 r.GetName(),
+			Inputs:               properties,
+			Outputs:              properties,
+			PropertyDependencies: dependencies,
+		})
+	}
+
+	policies := map[string]ResourceValidationPolicy{}
+	for _, policy := range a.pack.Policies {
+		policies[policy.Name] = policy
+	}
+
+	var diagnostics []*pulumirpc.AnalyzeDiagnostic
+	for _, violation := range a.pack.Check(a.stack, resources) {
+		diagnostics = append(diagnostics, &pulumirpc.AnalyzeDiagnostic{
+			PolicyName:        violation.Policy,
+			PolicyPackName:    a.pack.Name,
+			PolicyPackVersion: a.pack.Version,
+			Description:       policies[violation.Policy].Description,
+			Message:           violation.Message,
+			EnforcementLevel:  enforcementLevel(violation.EnforcementLevel),
+			Urn:               violation.Resource.URN,
+		})
+	}
+	return &pulumirpc.AnalyzeResponse{Diagnostics: diagnostics}, nil
+}
+
+func (a *Analyzer) GetAnalyzerInfo(ctx context.Context, _ *emptypb.Empty) (*pulumirpc.AnalyzerInfo, error) {
+	info := &pulumirpc.AnalyzerInfo{
+		Name:        a.pack.Name,
+		DisplayName: a.pack.Name,
+		Version:     a.pack.Version,
+	}
+	for _, policy := range a.pack.Policies {
+		info.Policies = append(info.Policies, &pulumirpc.PolicyInfo{
+			Name:             policy.Name,
+			DisplayName:      policy.Name,
+			Description:      policy.Description,
+			EnforcementLevel: enforcementLevel(policy.EnforcementLevel),
+		})
+	}
+	return info, nil
+}
+
+func (a *Analyzer) GetPluginInfo(ctx context.Context, _ *emptypb.Empty) (*pulumirpc.PluginInfo, error) {
+	return &pulumirpc.PluginInfo{Version: a.pack.Version}, nil
+}
+
+// Configure accepts the policy config of the stack. The policies of the
+// baseline take no config.
+func (a *Analyzer) Configure(ctx context.Context, req *pulumirpc.ConfigureAnalyzerRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func enforcementLevel(level EnforcementLevel) pulumirpc.EnforcementLevel {
+	if level == Mandatory {
+		return pulumirpc.EnforcementLevel_MANDATORY
+	}
+	return pulumirpc.EnforcementLevel_ADVISORY
+}
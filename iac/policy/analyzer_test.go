@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func analyzerResource(t *testing.T, typeToken, name string, properties map[string]interface{}) *pulumirpc.AnalyzerResource {
+	t.Helper()
+	props, err := structpb.NewStruct(properties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pulumirpc.AnalyzerResource{
+		Type:       typeToken,
+		Name:       name,
+		Urn:        "urn:pulumi:prod::air-tek-iac::" + typeToken + "::" + name,
+		Properties: props,
+	}
+}
+
+func TestAnalyzeStack(t *testing.T) {
+	analyzer := NewAnalyzer(Baseline(), "prod")
+	response, err := analyzer.AnalyzeStack(context.Background(), &pulumirpc.AnalyzeStackRequest{
+		Resources: []*pulumirpc.AnalyzerResource{
+			analyzerResource(t, "aws:ec2/securityGroup:SecurityGroup", "api-lb",
+				map[string]interface{}{"ingress": ingress(5000, "0.0.0.0/0"), "tags": withTags(nil)}),
+			analyzerResource(t, "aws:ecr/repository:Repository", "api-ecr",
+				map[string]interface{}{"forceDelete": true, "imageScanningConfiguration": map[string]interface{}{"scanOnPush": unknownValue}, "tags": withTags(nil)}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]pulumirpc.EnforcementLevel{
+		"no-world-open-ingress":         pulumirpc.EnforcementLevel_MANDATORY,
+		"no-force-delete-in-production": pulumirpc.EnforcementLevel_MANDATORY,
+	}
+	if len(response.Diagnostics) != len(want) {
+		t.Fatalf("diagnostics = %v, want %d", response.Diagnostics, len(want))
+	}
+	for _, diagnostic := range response.Diagnostics {
+		level, ok := want[diagnostic.PolicyName]
+		if !ok || diagnostic.EnforcementLevel != level {
+			t.Errorf("unexpected diagnostic %v", diagnostic)
+		}
+		if diagnostic.PolicyPackName != "air-tek-baseline" || diagnostic.Urn == "" || diagnostic.Message == "" {
+			t.Errorf("diagnostic %s misses pack, urn or message: %v", diagnostic.PolicyName, diagnostic)
+		}
+	}
+}
+
+func secret(value interface{}) map[string]interface{} {
+	return map[string]interface{}{secretSignature: "1b47061264138c4ac30d75fd1eb44270", "value": value}
+}
+
+func TestAnalyzeStackChecksSecrets(t *testing.T) {
+	analyzer := NewAnalyzer(Baseline(), "dev")
+	response, err := analyzer.AnalyzeStack(context.Background(), &pulumirpc.AnalyzeStackRequest{
+		Resources: []*pulumirpc.AnalyzerResource{
+			analyzerResource(t, "aws:ecr/repository:Repository", "api-ecr",
+				map[string]interface{}{"imageScanningConfiguration": map[string]interface{}{"scanOnPush": secret(false)}, "tags": secret(withTags(nil))}),
+			analyzerResource(t, "aws:ecr/repository:Repository", "ui-ecr",
+				map[string]interface{}{"imageScanningConfiguration": map[string]interface{}{"scanOnPush": secret(true)}, "tags": secret(map[string]interface{}{})}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"ecr-scan-on-push": "api-ecr", "required-tags": "ui-ecr"}
+	if len(response.Diagnostics) != len(want) {
+		t.Fatalf("diagnostics = %v, want %d", response.Diagnostics, len(want))
+	}
+	for _, diagnostic := range response.Diagnostics {
+		if name, ok := want[diagnostic.PolicyName]; !ok || !strings.HasSuffix(diagnostic.Urn, "::"+name) {
+			t.Errorf("unexpected diagnostic %v", diagnostic)
+		}
+	}
+}
+
+func TestGetAnalyzerInfo(t *testing.T) {
+	info, err := NewAnalyzer(Baseline(), "dev").GetAnalyzerInfo(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "air-tek-baseline" || len(info.Policies) != 5 {
+		t.Fatalf("info = %v, want the five baseline policies", info)
+	}
+	for _, policy := range info.Policies {
+		if policy.Description == "" {
+			t.Errorf("policy %s has no description", policy.Name)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package policy is the security baseline every stack is checked against, a
+// CrossGuard policy pack of resource validation policies. The Pulumi CLI runs
+// it with `pulumi preview --policy-pack policy/pack`, see Analyzer.
+package policy
+
+import (
+	"fmt"
+	"sort"
+)
+
+type EnforcementLevel string
+
+const (
+	// Advisory violations are reported but don't fail the preview.
+	Advisory EnforcementLevel = "advisory"
+	// Mandatory violations fail the preview or update.
+	Mandatory EnforcementLevel = "mandatory"
+)
+
+// unknownValue is the placeholder the engine uses for values that are only
+// known after the update.
+const unknownValue = "04da6b54-80e4-46f7-96ec-b56ff0331ba9"
+
+// secretSignature marks a secret value in serialized properties.
+const secretSignature = "4dabf18193072939515e22adb298388d"
+
+// Resource is a resource as the engine hands it to the policy pack. Values
+// the engine does not know yet are left as unknown placeholders and are
+// skipped by policies. Secret values are unwrapped, see revealSecrets.
+type Resource struct {
+	URN     string
+	Type    string
+	Name    string
+	Inputs  map[string]interface{}
+	Outputs map[string]interface{}
+	// PropertyDependencies maps a property to the URNs of the resources
+	// its value comes from.
+	PropertyDependencies map[string][]string
+}
+
+// ResourceValidationArgs is what a policy gets for every resource.
+type ResourceValidationArgs struct {
+	Resource Resource
+	// Stack is the name of the stack being checked.
+	Stack string
+	// Resources holds every resource in the stack, for policies that need
+	// to look at the resources this one refers to.
+	Resources []Resource
+}
+
+// ReportViolation records a violation of the policy being run.
+type ReportViolation func(message string)
+
+// ResourceValidationPolicy validates single resources.
+type ResourceValidationPolicy struct {
+	Name             string
+	Description      string
+	EnforcementLevel EnforcementLevel
+	ValidateResource func(args ResourceValidationArgs, reportViolation ReportViolation)
+}
+
+type Violation struct {
+	Policy           string
+	EnforcementLevel EnforcementLevel
+	Resource         Resource
+	Message          string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s [%s] %s %s: %s", v.EnforcementLevel, v.Policy, v.Resource.Type, v.Resource.Name, v.Message)
+}
+
+type PolicyPack struct {
+	Name     string
+	Version  string
+	Policies []ResourceValidationPolicy
+}
+
+// Check runs every policy of the pack against every resource and returns
+// the violations, mandatory ones first.
+func (p *PolicyPack) Check(stack string, resources []Resource) []Violation {
+	var violations []Violation
+	for _, resource := range resources {
+		for _, policy := range p.Policies {
+			args := ResourceValidationArgs{Resource: resource, Stack: stack, Resources: resources}
+			policy.ValidateResource(args, func(message string) {
+				violations = append(violations, Violation{
+					Policy:           policy.Name,
+					EnforcementLevel: policy.EnforcementLevel,
+					Resource:         resource,
+					Message:          message,
+				})
+			})
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].EnforcementLevel == Mandatory && violations[j].EnforcementLevel != Mandatory
+	})
+	return violations
+}
+
+func isUnknown(value interface{}) bool {
+	value, ok := value.(string)
+	return ok && value == unknownValue
+}
+
+// revealSecrets replaces the secrets in a serialized value with the values
+// they wrap, so a secret is checked like any other value. A secret whose
+// value isn't known is left as an unknown placeholder.
+func revealSecrets(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if _, secret := value[secretSignature]; secret {
+			plain, ok := value["value"]
+			if !ok {
+				return unknownValue
+			}
+			return revealSecrets(plain)
+		}
+		revealed := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			revealed[key] = revealSecrets(item)
+		}
+		return revealed
+	case []interface{}:
+		revealed := make([]interface{}, len(value))
+		for i, item := range value {
+			revealed[i] = revealSecrets(item)
+		}
+		return revealed
+	}
+	return value
+}
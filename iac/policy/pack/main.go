@@ -0,0 +1,46 @@
+// Command pack is the baseline policy pack. The Pulumi CLI starts it through
+// the pulumi-analyzer-policy-go plugin:
+//
+//	PULUMI_STACK=dev pulumi preview --stack dev --policy-pack policy/pack
+//
+// It serves the analyzer on a free port and prints the port for the CLI.
+package main
+
+import (
+	"air-tek-iac/policy"
+	"fmt"
+	"os"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/rpcutil"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	// The stack being checked comes from the environment. The engine doesn't
+	// hand it to analyzers, and without it the production checks would only
+	// look at tags, so the pack refuses to run.
+	stack := os.Getenv("PULUMI_STACK")
+	if stack == "" {
+		fmt.Fprintln(os.Stderr, "PULUMI_STACK is not set, the policy pack needs the name of the stack being checked")
+		os.Exit(1)
+	}
+	analyzer := policy.NewAnalyzer(policy.Baseline(), stack)
+
+	handle, err := rpcutil.ServeWithOptions(rpcutil.ServeOptions{
+		Init: func(server *grpc.Server) error {
+			pulumirpc.RegisterAnalyzerServer(server, analyzer)
+			return nil
+		},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(handle.Port)
+
+	if err := <-handle.Done; err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,260 @@
+package policy
+
+import (
+	"air-tek-iac/core"
+	"fmt"
+	"strings"
+)
+
+// Baseline is the security baseline for every air-tek stack.
+func Baseline() *PolicyPack {
+	return &PolicyPack{
+		Name:    "air-tek-baseline",
+		Version: "0.1.0",
+		Policies: []ResourceValidationPolicy{
+			noWorldOpenIngress,
+			noInternalLoadBalancerInPublicSubnets,
+			ecrScanOnPush,
+			requiredTags,
+			noForceDeleteInProduction,
+		},
+	}
+}
+
+var worldCidrs = map[string]bool{"0.0.0.0/0": true, "::/0": true}
+
+var noWorldOpenIngress = ResourceValidationPolicy{
+	Name:             "no-world-open-ingress",
+	Description:      "Only security groups tagged " + core.ExposureTag + "=public may allow ingress from anywhere.",
+	EnforcementLevel: Mandatory,
+	ValidateResource: func(args ResourceValidationArgs, report ReportViolation) {
+		resource := args.Resource
+		switch resource.Type {
+		case "aws:ec2/securityGroup:SecurityGroup":
+			if isPublicSecurityGroup(resource) {
+				return
+			}
+			for _, rule := range objects(resource.Inputs, "ingress") {
+				if cidr, ok := worldOpen(rule); ok {
+					report(fmt.Sprintf("ingress on port %v allows %s", rule["fromPort"], cidr))
+				}
+			}
+		case "aws:ec2/securityGroupRule:SecurityGroupRule":
+			if stringValue(resource.Inputs, "type") != "ingress" {
+				return
+			}
+			cidr, ok := worldOpen(resource.Inputs)
+			if !ok {
+				return
+			}
+			groups := referenced(args, "securityGroupId", "aws:ec2/securityGroup:SecurityGroup")
+			// On a first preview the group id is unknown, and a group
+			// outside the stack can't be checked either.
+			if len(groups) == 0 && isUnknown(resource.Inputs["securityGroupId"]) {
+				return
+			}
+			for _, group := range groups {
+				if isPublicSecurityGroup(group) {
+					return
+				}
+			}
+			report(fmt.Sprintf("ingress on port %v allows %s", resource.Inputs["fromPort"], cidr))
+		}
+	},
+}
+
+var noInternalLoadBalancerInPublicSubnets = ResourceValidationPolicy{
+	Name:             "no-internal-alb-in-public-subnets",
+	Description:      "Internal load balancers must be placed in private subnets.",
+	EnforcementLevel: Mandatory,
+	ValidateResource: func(args ResourceValidationArgs, report ReportViolation) {
+		resource := args.Resource
+		if resource.Type != "aws:lb/loadBalancer:LoadBalancer" && resource.Type != "aws:elasticloadbalancingv2/loadBalancer:LoadBalancer" {
+			return
+		}
+		if !boolValue(resource.Inputs, "internal") {
+			return
+		}
+		for _, subnet := range referenced(args, "subnets", "aws:ec2/subnet:Subnet") {
+			if boolValue(subnet.Inputs, "mapPublicIpOnLaunch") {
+				report(fmt.Sprintf("internal load balancer is placed in public subnet %s", subnet.Name))
+			}
+		}
+	},
+}
+
+var ecrScanOnPush = ResourceValidationPolicy{
+	Name:             "ecr-scan-on-push",
+	Description:      "ECR repositories must scan images when they are pushed.",
+	EnforcementLevel: Advisory,
+	ValidateResource: func(args ResourceValidationArgs, report ReportViolation) {
+		resource := args.Resource
+		if resource.Type != "aws:ecr/repository:Repository" {
+			return
+		}
+		scanning := object(resource.Inputs, "imageScanningConfiguration")
+		if value, ok := scanning["scanOnPush"]; isUnknown(value) || (ok && value == true) {
+			return
+		}
+		report("image scanning on push is not enabled")
+	},
+}
+
+// requiredTagKeys are the tags core.RegisterTagging always applies.
+var requiredTagKeys = []string{core.ProjectTag, core.StackTag, core.NetworkTag, core.EnvironmentTag}
+
+var requiredTags = ResourceValidationPolicy{
+	Name:             "required-tags",
+	Description:      "Taggable resources must carry the standard air-tek tags.",
+	EnforcementLevel: Advisory,
+	ValidateResource: func(args ResourceValidationArgs, report ReportViolation) {
+		resource := args.Resource
+		if !isTaggable(resource) {
+			return
+		}
+		tags, ok := resource.Inputs["tags"]
+		if isUnknown(tags) {
+			return
+		}
+		tagMap, _ := tags.(map[string]interface{})
+		var missing []string
+		for _, key := range requiredTagKeys {
+			if value, found := tagMap[key]; !ok || !found || value == "" {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			report("missing tags " + strings.Join(missing, ", "))
+		}
+	},
+}
+
+var productionEnvironments = map[string]bool{"prod": true, "production": true}
+
+var noForceDeleteInProduction = ResourceValidationPolicy{
+	Name:             "no-force-delete-in-production",
+	Description:      "Production stacks must not force delete ECR repositories that still hold images.",
+	EnforcementLevel: Mandatory,
+	ValidateResource: func(args ResourceValidationArgs, report ReportViolation) {
+		resource := args.Resource
+		if resource.Type != "aws:ecr/repository:Repository" || !boolValue(resource.Inputs, "forceDelete") {
+			return
+		}
+		environment := stringValue(object(resource.Inputs, "tags"), core.EnvironmentTag)
+		if productionEnvironments[args.Stack] || productionEnvironments[environment] {
+			report("forceDelete is enabled on a production stack")
+		}
+	},
+}
+
+func isPublicSecurityGroup(group Resource) bool {
+	return stringValue(object(group.Inputs, "tags"), core.ExposureTag) == "public"
+}
+
+func worldOpen(rule map[string]interface{}) (string, bool) {
+	for _, key := range []string{"cidrBlocks", "ipv6CidrBlocks"} {
+		for _, cidr := range stringSlice(rule, key) {
+			if worldCidrs[cidr] {
+				return cidr, true
+			}
+		}
+	}
+	return "", false
+}
+
+// taggableTypes lists the resource types of this repository that take tags,
+// for previews that don't show tagsAll yet.
+var taggableTypes = map[string]bool{
+	"aws:ec2/vpc:Vpc":                                      true,
+	"aws:ec2/subnet:Subnet":                                true,
+	"aws:ec2/internetGateway:InternetGateway":              true,
+	"aws:ec2/natGateway:NatGateway":                        true,
+	"aws:ec2/eip:Eip":                                      true,
+	"aws:ec2/routeTable:RouteTable":                        true,
+	"aws:ec2/securityGroup:SecurityGroup":                  true,
+	"aws:ec2/vpcEndpoint:VpcEndpoint":                      true,
+	"aws:ec2/flowLog:FlowLog":                              true,
+	"aws:cloudwatch/logGroup:LogGroup":                     true,
+	"aws:s3/bucketV2:BucketV2":                             true,
+	"aws:ecr/repository:Repository":                        true,
+	"aws:ecs/cluster:Cluster":                              true,
+	"aws:ecs/service:Service":                              true,
+	"aws:ecs/taskDefinition:TaskDefinition":                true,
+	"aws:iam/role:Role":                                    true,
+	"aws:lb/loadBalancer:LoadBalancer":                     true,
+	"aws:lb/targetGroup:TargetGroup":                       true,
+	"aws:lb/listener:Listener":                             true,
+	"aws:lb/listenerRule:ListenerRule":                     true,
+	"aws:elasticloadbalancingv2/loadBalancer:LoadBalancer": true,
+	"aws:elasticloadbalancingv2/targetGroup:TargetGroup":   true,
+	"aws:elasticloadbalancingv2/listener:Listener":         true,
+	"aws:elasticloadbalancingv2/listenerRule:ListenerRule": true,
+	"aws:acm/certificate:Certificate":                      true,
+}
+
+func isTaggable(resource Resource) bool {
+	if taggableTypes[resource.Type] {
+		return true
+	}
+	_, hasTagsAll := resource.Outputs["tagsAll"]
+	return strings.HasPrefix(resource.Type, "aws:") && hasTagsAll
+}
+
+// referenced returns the resources of the given type that a property of the
+// resource refers to, found through the property's dependencies. The engine
+// doesn't hand ids to the pack, so a resource outside the stack can't be
+// found.
+func referenced(args ResourceValidationArgs, key, typeToken string) []Resource {
+	var found []Resource
+	for _, urn := range args.Resource.PropertyDependencies[key] {
+		for _, resource := range args.Resources {
+			if resource.URN == urn && resource.Type == typeToken {
+				found = append(found, resource)
+			}
+		}
+	}
+	return found
+}
+
+func object(values map[string]interface{}, key string) map[string]interface{} {
+	value, _ := values[key].(map[string]interface{})
+	if isUnknown(value) {
+		return nil
+	}
+	return value
+}
+
+func objects(values map[string]interface{}, key string) []map[string]interface{} {
+	items, _ := values[key].([]interface{})
+	var result []map[string]interface{}
+	for _, item := range items {
+		if value, ok := item.(map[string]interface{}); ok && !isUnknown(value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func stringValue(values map[string]interface{}, key string) string {
+	value, _ := values[key].(string)
+	if isUnknown(value) {
+		return ""
+	}
+	return value
+}
+
+func boolValue(values map[string]interface{}, key string) bool {
+	value, _ := values[key].(bool)
+	return value
+}
+
+func stringSlice(values map[string]interface{}, key string) []string {
+	items, _ := values[key].([]interface{})
+	var result []string
+	for _, item := range items {
+		if value, ok := item.(string); ok && !isUnknown(value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,175 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+var standardTags = map[string]interface{}{
+	"air-tek:project":     "air-tek-iac",
+	"air-tek:stack":       "dev",
+	"air-tek:network":     "us1",
+	"air-tek:environment": "dev",
+}
+
+func withTags(extra map[string]interface{}) map[string]interface{} {
+	tags := map[string]interface{}{}
+	for key, value := range standardTags {
+		tags[key] = value
+	}
+	for key, value := range extra {
+		tags[key] = value
+	}
+	return tags
+}
+
+func ingress(port float64, cidrs ...interface{}) []interface{} {
+	return []interface{}{map[string]interface{}{"fromPort": port, "toPort": port, "protocol": "tcp", "cidrBlocks": cidrs}}
+}
+
+func violationsOf(t *testing.T, stack string, resources ...Resource) []string {
+	t.Helper()
+	var names []string
+	for _, violation := range Baseline().Check(stack, resources) {
+		names = append(names, violation.Policy)
+	}
+	return names
+}
+
+func TestBaseline(t *testing.T) {
+	publicSubnet := Resource{Type: "aws:ec2/subnet:Subnet", Name: "public-1a", URN: "urn:public-1a",
+		Inputs: map[string]interface{}{"mapPublicIpOnLaunch": true, "tags": withTags(nil)}}
+	privateSubnet := Resource{Type: "aws:ec2/subnet:Subnet", Name: "private-1a", URN: "urn:private-1a",
+		Inputs: map[string]interface{}{"mapPublicIpOnLaunch": false, "tags": withTags(nil)}}
+
+	tests := []struct {
+		name      string
+		stack     string
+		resources []Resource
+		want      []string
+	}{
+		{
+			name: "world open ingress on an internal security group",
+			resources: []Resource{{Type: "aws:ec2/securityGroup:SecurityGroup", Name: "api-lb",
+				Inputs: map[string]interface{}{"ingress": ingress(5000, "0.0.0.0/0"), "tags": withTags(nil)}}},
+			want: []string{"no-world-open-ingress"},
+		},
+		{
+			name: "world open ingress on a public security group",
+			resources: []Resource{{Type: "aws:ec2/securityGroup:SecurityGroup", Name: "ui-lb",
+				Inputs: map[string]interface{}{"ingress": ingress(443, "0.0.0.0/0"), "tags": withTags(map[string]interface{}{"air-tek:exposure": "public"})}}},
+		},
+		{
+			name: "world open security group rule",
+			resources: []Resource{
+				{Type: "aws:ec2/securityGroup:SecurityGroup", Name: "api-lb", URN: "urn:api-lb", Inputs: map[string]interface{}{"tags": withTags(nil)}},
+				{Type: "aws:ec2/securityGroupRule:SecurityGroupRule", Name: "api-lb-any",
+					Inputs:               map[string]interface{}{"type": "ingress", "fromPort": 5000.0, "cidrBlocks": []interface{}{"::/0"}, "securityGroupId": "sg-1"},
+					PropertyDependencies: map[string][]string{"securityGroupId": {"urn:api-lb"}}},
+			},
+			want: []string{"no-world-open-ingress"},
+		},
+		{
+			name: "world open rule on a public group with unknown ids",
+			resources: []Resource{
+				{Type: "aws:ec2/securityGroup:SecurityGroup", Name: "ui-lb", URN: "urn:ui-lb",
+					Inputs: map[string]interface{}{"tags": withTags(map[string]interface{}{"air-tek:exposure": "public"})}},
+				{Type: "aws:ec2/securityGroupRule:SecurityGroupRule", Name: "ui-lb-https",
+					Inputs:               map[string]interface{}{"type": "ingress", "fromPort": 443.0, "cidrBlocks": []interface{}{"0.0.0.0/0"}, "securityGroupId": unknownValue},
+					PropertyDependencies: map[string][]string{"securityGroupId": {"urn:ui-lb"}}},
+			},
+		},
+		{
+			name: "world open rule on an internal group with unknown ids",
+			resources: []Resource{
+				{Type: "aws:ec2/securityGroup:SecurityGroup", Name: "api-lb", URN: "urn:api-lb", Inputs: map[string]interface{}{"tags": withTags(nil)}},
+				{Type: "aws:ec2/securityGroupRule:SecurityGroupRule", Name: "api-lb-any",
+					Inputs:               map[string]interface{}{"type": "ingress", "fromPort": 5000.0, "cidrBlocks": []interface{}{"0.0.0.0/0"}, "securityGroupId": unknownValue},
+					PropertyDependencies: map[string][]string{"securityGroupId": {"urn:api-lb"}}},
+			},
+			want: []string{"no-world-open-ingress"},
+		},
+		{
+			name: "world open rule on a group that isn't known yet",
+			resources: []Resource{{Type: "aws:ec2/securityGroupRule:SecurityGroupRule", Name: "ui-lb-https",
+				Inputs: map[string]interface{}{"type": "ingress", "fromPort": 443.0, "cidrBlocks": []interface{}{"0.0.0.0/0"}, "securityGroupId": unknownValue}}},
+		},
+		{
+			name: "ingress from the vpc",
+			resources: []Resource{{Type: "aws:ec2/securityGroup:SecurityGroup", Name: "api-lb",
+				Inputs: map[string]interface{}{"ingress": ingress(5000, "10.1.0.0/16"), "tags": withTags(nil)}}},
+		},
+		{
+			name: "internal load balancer in a public subnet",
+			resources: []Resource{publicSubnet, {Type: "aws:lb/loadBalancer:LoadBalancer", Name: "api-lb",
+				Inputs:               map[string]interface{}{"internal": true, "subnets": []interface{}{"subnet-public"}, "tags": withTags(nil)},
+				PropertyDependencies: map[string][]string{"subnets": {"urn:public-1a"}}}},
+			want: []string{"no-internal-alb-in-public-subnets"},
+		},
+		{
+			name: "internal load balancer in a private subnet",
+			resources: []Resource{privateSubnet, {Type: "aws:lb/loadBalancer:LoadBalancer", Name: "api-lb",
+				Inputs:               map[string]interface{}{"internal": true, "subnets": []interface{}{"subnet-private"}, "tags": withTags(nil)},
+				PropertyDependencies: map[string][]string{"subnets": {"urn:private-1a"}}}},
+		},
+		{
+			name: "subnet ids not known yet",
+			resources: []Resource{{Type: "aws:lb/loadBalancer:LoadBalancer", Name: "api-lb",
+				Inputs: map[string]interface{}{"internal": true, "subnets": unknownValue, "tags": withTags(nil)}}},
+		},
+		{
+			name: "ecr repository without scan on push",
+			resources: []Resource{{Type: "aws:ecr/repository:Repository", Name: "api-ecr",
+				Inputs: map[string]interface{}{"tags": withTags(nil)}}},
+			want: []string{"ecr-scan-on-push"},
+		},
+		{
+			name: "untagged resources",
+			resources: []Resource{
+				{Type: "aws:iam/role:Role", Name: "task-exec-role", Inputs: map[string]interface{}{}},
+				{Type: "aws:cloudwatch/logGroup:LogGroup", Name: "logs", Inputs: map[string]interface{}{"tags": map[string]interface{}{"Name": "logs"}}, Outputs: map[string]interface{}{"tagsAll": unknownValue}},
+				{Type: "aws:ec2/routeTableAssociation:RouteTableAssociation", Name: "association", Inputs: map[string]interface{}{}},
+			},
+			want: []string{"required-tags", "required-tags"},
+		},
+		{
+			name:  "force delete on a production stack",
+			stack: "prod",
+			resources: []Resource{{Type: "aws:ecr/repository:Repository", Name: "api-ecr",
+				Inputs: map[string]interface{}{"forceDelete": true, "imageScanningConfiguration": map[string]interface{}{"scanOnPush": true}, "tags": withTags(nil)}}},
+			want: []string{"no-force-delete-in-production"},
+		},
+		{
+			name:  "force delete on a production environment",
+			stack: "eu1",
+			resources: []Resource{{Type: "aws:ecr/repository:Repository", Name: "api-ecr",
+				Inputs: map[string]interface{}{"forceDelete": true, "imageScanningConfiguration": map[string]interface{}{"scanOnPush": true}, "tags": withTags(map[string]interface{}{"air-tek:environment": "production"})}}},
+			want: []string{"no-force-delete-in-production"},
+		},
+		{
+			name:  "force delete on a development stack",
+			stack: "dev",
+			resources: []Resource{{Type: "aws:ecr/repository:Repository", Name: "api-ecr",
+				Inputs: map[string]interface{}{"forceDelete": true, "imageScanningConfiguration": map[string]interface{}{"scanOnPush": true}, "tags": withTags(nil)}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := violationsOf(t, test.stack, test.resources...)
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("violations = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckOrdersMandatoryFirst(t *testing.T) {
+	violations := Baseline().Check("dev", []Resource{
+		{Type: "aws:ecr/repository:Repository", Name: "api-ecr", Inputs: map[string]interface{}{"tags": withTags(nil)}},
+		{Type: "aws:ec2/securityGroup:SecurityGroup", Name: "api-lb", Inputs: map[string]interface{}{"ingress": ingress(5000, "0.0.0.0/0"), "tags": withTags(nil)}},
+	})
+	if len(violations) != 2 || violations[0].EnforcementLevel != Mandatory {
+		t.Fatalf("violations = %v", violations)
+	}
+}
@@ -0,0 +1,41 @@
+// Command pulumi-analyzer-policy-go runs Go policy packs, the ones whose
+// PulumiPolicy.yaml has runtime go. The Pulumi CLI looks it up on the PATH:
+//
+//	go install ./policy/pulumi-analyzer-policy-go
+//
+// The CLI starts it in the policy pack directory. It builds the pack and
+// replaces itself with it, so the pack prints its port to the CLI and stops
+// when the CLI stops the plugin.
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "starting policy pack: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	dir, err := os.MkdirTemp("", "pulumi-policy-pack")
+	if err != nil {
+		return err
+	}
+	pack := filepath.Join(dir, "pack")
+
+	build := exec.Command("go", "build", "-o", pack, ".")
+	build.Stdout = os.Stderr
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		return fmt.Errorf("building: %w", err)
+	}
+
+	return syscall.Exec(pack, append([]string{pack}, os.Args[1:]...), os.Environ())
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"air-tek-iac/core"
+	"air-tek-iac/services"
+	"air-tek-iac/testutil"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// TestStackPassesBaseline runs the application stack against mocks and
+// checks it against the baseline the way a first preview sees it: no
+// resource has an id yet and every reference to one is unknown.
+func TestStackPassesBaseline(t *testing.T) {
+	specs := []services.Spec{
+		{Name: "web-api", Port: 5000, Image: "registry.example.com/web-api:1", Exposure: services.InternalExposure},
+		{Name: "web-ui", Port: 5000, ListenerPort: 80, Image: "registry.example.com/web-ui:1", Exposure: services.PublicExposure,
+			Environment: map[string]string{"ApiAddress": "${web-api.url}/WeatherForecast"}},
+	}
+
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		if err := core.RegisterTagging(ctx, "test"); err != nil {
+			return err
+		}
+		platform, err := core.NewPlatform(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = services.Deploy(ctx, platform, specs, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resources []Resource
+	for _, r := range mocks.All() {
+		if !r.Custom {
+			continue
+		}
+		resources = append(resources, Resource{
+			URN:                  r.URN,
+			Type:                 r.TypeToken,
+			Name:                 r.Name,
+			Inputs:               withUnknownIds(r.Inputs.Mappable()).(map[string]interface{}),
+			PropertyDependencies: r.PropertyDependencies,
+		})
+	}
+
+	for _, violation := range Baseline().Check(testutil.Stack, resources) {
+		if violation.EnforcementLevel == Mandatory {
+			t.Error(violation)
+		}
+	}
+}
+
+// withUnknownIds replaces the mocked ids, which end in -id, with unknowns.
+func withUnknownIds(value interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		if strings.HasSuffix(value, "-id") {
+			return unknownValue
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = withUnknownIds(item)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = withUnknownIds(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"air-tek-iac/core"
+	"air-tek-iac/utils"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Load reads the services manifest from the project's `services` config key
+// and applies the deploy-time overrides each service can take from its own
+// namespace (image, imageTag, domainName, hostedZoneId), e.g. webApi:imageTag
+// for web-api. The returned specs are validated and in deployment order. The
+// optional `loadBalancers` key holds the load balancers services can share.
+func Load(ctx *pulumi.Context) ([]Spec, []LoadBalancerSpec, error) {
+	project := config.New(ctx, "")
+
+	var specs []Spec
+	if err := project.TryObject("services", &specs); err != nil {
+		if errors.Is(err, config.ErrMissingVar) {
+			return nil, nil, fmt.Errorf("no services manifest, set %s:services in the stack config", ctx.Project())
+		}
+		return nil, nil, fmt.Errorf("reading services manifest: %w", err)
+	}
+
+	var loadBalancers []LoadBalancerSpec
+	if err := project.TryObject("loadBalancers", &loadBalancers); err != nil && !errors.Is(err, config.ErrMissingVar) {
+		return nil, nil, fmt.Errorf("reading load balancers: %w", err)
+	}
+
+	for i := range specs {
+		overrides := config.New(ctx, configNamespace(specs[i].Name))
+		if image := overrides.Get("image"); image != "" {
+			specs[i].Image = image
+			specs[i].ImageTag = ""
+		}
+		if imageTag := overrides.Get("imageTag"); imageTag != "" {
+			specs[i].Image = ""
+			specs[i].ImageTag = imageTag
+		}
+		if domainName := overrides.Get("domainName"); domainName != "" {
+			specs[i].DomainName = domainName
+		}
+		if hostedZoneId := overrides.Get("hostedZoneId"); hostedZoneId != "" {
+			specs[i].HostedZoneId = hostedZoneId
+		}
+	}
+
+	specs, err := Validate(specs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ValidateLoadBalancers(loadBalancers, specs); err != nil {
+		return nil, nil, err
+	}
+	return specs, loadBalancers, nil
+}
+
+// Deploy creates the shared load balancers and a FargateService for every
+// spec on the given platform. The specs must be in the order returned by
+// Validate so referenced services exist before the services whose
+// environment points at them.
+func Deploy(ctx *pulumi.Context, platform *core.Platform, specs []Spec, loadBalancers []LoadBalancerSpec) (map[string]*utils.FargateService, error) {
+	shared, err := deployLoadBalancers(ctx, platform, specs, loadBalancers)
+	if err != nil {
+		return nil, err
+	}
+
+	deployed := map[string]*utils.FargateService{}
+
+	for _, spec := range specs {
+		loadBalancerSecurityGroup, taskSecurityGroup, err := serviceSecurityGroups(platform, spec)
+		if err != nil {
+			return nil, err
+		}
+
+		var sharedLoadBalancer *utils.SharedLoadBalancer
+		var rule utils.ListenerRuleArgs
+		if spec.LoadBalancer != "" {
+			sharedLoadBalancer = shared[spec.LoadBalancer]
+			if sharedLoadBalancer == nil {
+				return nil, fmt.Errorf("service %s: unknown load balancer %s", spec.Name, spec.LoadBalancer)
+			}
+			rule = *spec.Routing
+		}
+
+		taskSubnets := platform.PrivateSubnetIds
+		if spec.SubnetTier == utils.PublicSubnetTier {
+			taskSubnets = platform.PublicSubnetIds
+		}
+
+		environment := pulumi.StringMap{}
+		for key, value := range spec.Environment {
+			environment[key] = resolveReferences(value, deployed)
+		}
+
+		service, err := utils.NewFargateService(ctx, &utils.FargateServiceArgs{
+			Name:                       spec.Name,
+			NetworkName:                platform.NetworkName,
+			VpcId:                      platform.VpcId,
+			EcsClusterArn:              platform.EcsClusterArn,
+			LoadBalancerSubnets:        loadBalancerSubnets(platform, spec.Exposure),
+			LoadBalancerSecurityGroups: pulumi.StringArray{loadBalancerSecurityGroup},
+			Subnets:                    taskSubnets,
+			SecurityGroups:             pulumi.StringArray{taskSecurityGroup},
+			SubnetTier:                 spec.SubnetTier,
+			AssignPublicIp:             spec.AssignPublicIp,
+			Internal:                   spec.Exposure == InternalExposure,
+			SharedLoadBalancer:         sharedLoadBalancer,
+			Rule:                       rule,
+			Dockerfile:                 spec.Dockerfile,
+			BuildContext:               spec.BuildContext,
+			Image:                      spec.Image,
+			ImageTag:                   spec.ImageTag,
+			Port:                       spec.Port,
+			ListenerPort:               spec.ListenerPort,
+			HealthCheck:                spec.HealthCheck,
+			Environment:                environment,
+			Cpu:                        spec.Cpu,
+			Memory:                     spec.Memory,
+			DesiredCount:               spec.DesiredCount,
+			Autoscaling:                spec.Autoscaling,
+			DomainName:                 spec.DomainName,
+			HostedZoneId:               spec.HostedZoneId,
+			Logs:                       spec.Logs,
+			Registry:                   spec.Registry,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		deployed[spec.Name] = service
+	}
+
+	return deployed, nil
+}
+
+// deployLoadBalancers creates the shared load balancers. Each gets the load
+// balancer security groups of the services it routes to, as their tasks only
+// accept traffic from those.
+func deployLoadBalancers(ctx *pulumi.Context, platform *core.Platform, specs []Spec, loadBalancers []LoadBalancerSpec) (map[string]*utils.SharedLoadBalancer, error) {
+	shared := map[string]*utils.SharedLoadBalancer{}
+
+	for _, loadBalancer := range loadBalancers {
+		var securityGroups pulumi.StringArray
+		seen := map[string]bool{}
+		for _, spec := range specs {
+			if spec.LoadBalancer != loadBalancer.Name || seen[spec.securityGroup()] {
+				continue
+			}
+			seen[spec.securityGroup()] = true
+			securityGroup, _, err := serviceSecurityGroups(platform, spec)
+			if err != nil {
+				return nil, fmt.Errorf("load balancer %s: %w", loadBalancer.Name, err)
+			}
+			securityGroups = append(securityGroups, securityGroup)
+		}
+
+		lb, err := utils.NewSharedLoadBalancer(ctx, &utils.SharedLoadBalancerArgs{
+			LoadBalancerName: platform.NetworkName + "-" + loadBalancer.Name + "-lb",
+			VpcId:            platform.VpcId,
+			Subnets:          loadBalancerSubnets(platform, loadBalancer.Exposure),
+			SecurityGroups:   securityGroups,
+			ListenerPort:     loadBalancer.ListenerPort,
+			Internal:         loadBalancer.Exposure == InternalExposure,
+			DomainName:       loadBalancer.DomainName,
+			HostedZoneId:     loadBalancer.HostedZoneId,
+			DefaultResponse:  loadBalancer.DefaultResponse,
+			FixedResponses:   loadBalancer.FixedResponses,
+		})
+		if err != nil {
+			return nil, err
+		}
+		shared[loadBalancer.Name] = lb
+	}
+
+	return shared, nil
+}
+
+// serviceSecurityGroups returns the load balancer and task security groups of
+// a service, the ids it sets or the network's groups it names.
+func serviceSecurityGroups(platform *core.Platform, spec Spec) (loadBalancer pulumi.StringOutput, task pulumi.StringOutput, err error) {
+	if ids := spec.SecurityGroupIds; ids != nil {
+		return pulumi.String(ids.LoadBalancer).ToStringOutput(), pulumi.String(ids.Task).ToStringOutput(), nil
+	}
+	loadBalancer, task, err = platform.ServiceSecurityGroups(spec.securityGroup())
+	if err != nil {
+		return pulumi.StringOutput{}, pulumi.StringOutput{}, fmt.Errorf("service %s: %w, set securityGroupIds to use other groups", spec.Name, err)
+	}
+	return loadBalancer, task, nil
+}
+
+// loadBalancerSubnets places public load balancers in the public subnets and
+// internal ones in the private subnets.
+func loadBalancerSubnets(platform *core.Platform, exposure Exposure) pulumi.StringArrayInput {
+	if exposure == PublicExposure {
+		return platform.PublicSubnetIds
+	}
+	return platform.PrivateSubnetIds
+}
+
+// resolveReferences substitutes ${<service>.url} with the url of an already
+// deployed service.
+func resolveReferences(value string, deployed map[string]*utils.FargateService) pulumi.StringInput {
+	matches := referencePattern.FindAllStringSubmatch(value, -1)
+	if len(matches) == 0 {
+		return pulumi.String(value)
+	}
+
+	urls := make([]interface{}, len(matches))
+	for i, match := range matches {
+		urls[i] = deployed[match[1]].Url
+	}
+
+	return pulumi.All(urls...).ApplyT(func(resolved []interface{}) string {
+		i := 0
+		return referencePattern.ReplaceAllStringFunc(value, func(string) string {
+			url := resolved[i].(string)
+			i++
+			return url
+		})
+	}).(pulumi.StringOutput)
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
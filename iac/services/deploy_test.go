@@ -0,0 +1,302 @@
+package services
+
+import (
+	"air-tek-iac/core"
+	"air-tek-iac/testutil"
+	"air-tek-iac/utils"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+var testSpecs = []Spec{
+	{Name: "web-api", Port: 5000, Image: "registry.example.com/web-api:1", Exposure: InternalExposure},
+	{Name: "web-ui", Port: 5000, ListenerPort: 80, Image: "registry.example.com/web-ui:1", Exposure: PublicExposure,
+		Environment: map[string]string{"ApiAddress": "${web-api.url}/WeatherForecast"}},
+}
+
+func TestDeployTagsEveryTaggableResource(t *testing.T) {
+	config := testutil.DefaultConfig()
+	config["tags:owner"] = "platform-team"
+	config["tags:costCenter"] = "cc-1234"
+	config["tags:environment"] = "development"
+
+	var mu sync.Mutex
+	taggable := map[string]bool{}
+
+	mocks, err := testutil.Run(config, func(ctx *pulumi.Context) error {
+		if err := core.RegisterTagging(ctx, "test"); err != nil {
+			return err
+		}
+		err := ctx.RegisterStackTransformation(func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+			if core.IsTaggable(args.Props) {
+				mu.Lock()
+				taggable[args.Type+"::"+args.Name] = true
+				mu.Unlock()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		platform, err := core.NewPlatform(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = Deploy(ctx, platform, testSpecs, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		core.ProjectTag:     testutil.Project,
+		core.StackTag:       testutil.Stack,
+		core.NetworkTag:     "test",
+		core.EnvironmentTag: "development",
+		core.OwnerTag:       "platform-team",
+		core.CostCenterTag:  "cc-1234",
+	}
+
+	checked := 0
+	for _, r := range mocks.All() {
+		if !taggable[r.TypeToken+"::"+r.Name] {
+			continue
+		}
+		checked++
+		tags := r.Tags()
+		for key, value := range want {
+			if tags[key] != value {
+				t.Errorf("%s %s has %s=%q, want %q", r.TypeToken, r.Name, key, tags[key], value)
+			}
+		}
+	}
+	if checked < 20 {
+		t.Errorf("only %d taggable resources were checked", checked)
+	}
+}
+
+func TestDeployPlacesServicesByExposure(t *testing.T) {
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		platform, err := core.NewPlatform(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = Deploy(ctx, platform, testSpecs, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadBalancerType := "aws:elasticloadbalancingv2/loadBalancer:LoadBalancer"
+	if !mocks.Resource(t, loadBalancerType, "test-web-api-lb").BoolValue("internal") {
+		t.Error("web-api load balancer should be internal")
+	}
+	if mocks.Resource(t, loadBalancerType, "test-web-ui-lb").BoolValue("internal") {
+		t.Error("web-ui load balancer should be internet facing")
+	}
+}
+
+func TestDeployPlacesTasksBySubnetTier(t *testing.T) {
+	specs := []Spec{
+		{Name: "web-api", Port: 5000, Image: "registry.example.com/web-api:1"},
+		{Name: "web-ui", Port: 5000, Image: "registry.example.com/web-ui:1", Exposure: PublicExposure, SubnetTier: utils.PublicSubnetTier},
+	}
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		platform, err := core.NewPlatform(ctx)
+		if err != nil {
+			return err
+		}
+		specs, err := Validate(specs)
+		if err != nil {
+			return err
+		}
+		_, err = Deploy(ctx, platform, specs, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		tier           string
+		assignPublicIp bool
+	}{
+		"test-web-api-ecs-service": {tier: "private", assignPublicIp: false},
+		"test-web-ui-ecs-service":  {tier: "public", assignPublicIp: true},
+	}
+	for name, want := range tests {
+		service := mocks.Resource(t, "aws:ecs/service:Service", name)
+		network := service.Inputs["networkConfiguration"].ObjectValue()
+		if assign := network["assignPublicIp"].BoolValue(); assign != want.assignPublicIp {
+			t.Errorf("%s: assignPublicIp = %v, want %v", name, assign, want.assignPublicIp)
+		}
+		subnets := testutil.Strings(network, "subnets")
+		if len(subnets) == 0 {
+			t.Errorf("%s: no subnets", name)
+		}
+		for _, subnet := range subnets {
+			if !strings.Contains(subnet, "-"+want.tier+"-subnet-") {
+				t.Errorf("%s: subnet %s is not a %s subnet", name, subnet, want.tier)
+			}
+		}
+	}
+}
+
+func TestDeploySharesLoadBalancer(t *testing.T) {
+	specs := []Spec{
+		{Name: "web-api", Port: 5000, Image: "registry.example.com/web-api:1", Exposure: PublicExposure,
+			LoadBalancer: "edge", Routing: &utils.ListenerRuleArgs{Priority: 10, PathPatterns: []string{"/WeatherForecast*"}}},
+		{Name: "web-ui", Port: 5000, Image: "registry.example.com/web-ui:1", Exposure: PublicExposure,
+			LoadBalancer: "edge", Routing: &utils.ListenerRuleArgs{Priority: 20, PathPatterns: []string{"/*"}}},
+	}
+	loadBalancers := []LoadBalancerSpec{{Name: "edge", Exposure: PublicExposure}}
+
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		platform, err := core.NewPlatform(ctx)
+		if err != nil {
+			return err
+		}
+		specs, err := Validate(specs)
+		if err != nil {
+			return err
+		}
+		if err := ValidateLoadBalancers(loadBalancers, specs); err != nil {
+			return err
+		}
+		_, err = Deploy(ctx, platform, specs, loadBalancers)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	albs := mocks.Resources("aws:elasticloadbalancingv2/loadBalancer:LoadBalancer")
+	if len(albs) != 1 || albs[0].Name != "test-edge-lb" {
+		t.Fatalf("load balancers = %v, want only test-edge-lb", albs)
+	}
+	if groups := albs[0].StringArray("securityGroups"); len(groups) != 2 {
+		t.Errorf("shared load balancer security groups = %v, want those of web-api and web-ui", groups)
+	}
+	for _, subnet := range albs[0].StringArray("subnets") {
+		if !strings.Contains(subnet, "-public-subnet-") {
+			t.Errorf("public load balancer placed in %s", subnet)
+		}
+	}
+
+	for _, name := range []string{"web-api", "web-ui"} {
+		service := mocks.Resource(t, "aws:ecs/service:Service", "test-"+name+"-ecs-service")
+		targetGroupArn := service.Objects("loadBalancers")[0]["targetGroupArn"].StringValue()
+		if !strings.HasSuffix(targetGroupArn, "/test-"+name+"-route-tg") {
+			t.Errorf("%s registers with %s, want its route's target group", name, targetGroupArn)
+		}
+	}
+}
+
+func TestDeployInternalSharedLoadBalancer(t *testing.T) {
+	specs := []Spec{
+		{Name: "web-api", Port: 5000, Image: "registry.example.com/web-api:1",
+			LoadBalancer: "apis", Routing: &utils.ListenerRuleArgs{Priority: 10, PathPatterns: []string{"/WeatherForecast*"}}},
+	}
+	loadBalancers := []LoadBalancerSpec{{Name: "apis", ListenerPort: 5000}}
+
+	var url string
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		platform, err := core.NewPlatform(ctx)
+		if err != nil {
+			return err
+		}
+		specs, err := Validate(specs)
+		if err != nil {
+			return err
+		}
+		if err := ValidateLoadBalancers(loadBalancers, specs); err != nil {
+			return err
+		}
+		deployed, err := Deploy(ctx, platform, specs, loadBalancers)
+		if err != nil {
+			return err
+		}
+		deployed["web-api"].Url.ApplyT(func(value string) string {
+			url = value
+			return value
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alb := mocks.Resource(t, "aws:elasticloadbalancingv2/loadBalancer:LoadBalancer", "test-apis-lb")
+	if !alb.BoolValue("internal") {
+		t.Error("shared load balancer should be internal")
+	}
+	for _, subnet := range alb.StringArray("subnets") {
+		if !strings.Contains(subnet, "-private-subnet-") {
+			t.Errorf("internal load balancer placed in %s", subnet)
+		}
+	}
+	if groups := alb.StringArray("securityGroups"); len(groups) != 1 || groups[0] != "test-web-api-loadbalancer-security-group-id" {
+		t.Errorf("shared load balancer security groups = %v, want the web-api load balancer group", groups)
+	}
+	listener := mocks.Resource(t, "aws:elasticloadbalancingv2/listener:Listener", "test-apis-lb-listener")
+	if port := listener.Inputs["port"]; !port.IsNumber() || port.NumberValue() != 5000 {
+		t.Errorf("listener port = %v, want 5000, which the web-api load balancer group accepts", port)
+	}
+	if url != "http://test-apis-lb.elb.amazonaws.com:5000" {
+		t.Errorf("web-api url = %q", url)
+	}
+}
+
+func TestDeployUsesSecurityGroupIds(t *testing.T) {
+	specs := []Spec{
+		{Name: "worker", Port: 8080, Image: "registry.example.com/worker:1",
+			SecurityGroupIds: &SecurityGroupIds{LoadBalancer: "sg-0a1b", Task: "sg-0c2d"}},
+	}
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		platform, err := core.NewPlatform(ctx)
+		if err != nil {
+			return err
+		}
+		specs, err := Validate(specs)
+		if err != nil {
+			return err
+		}
+		_, err = Deploy(ctx, platform, specs, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alb := mocks.Resource(t, "aws:elasticloadbalancingv2/loadBalancer:LoadBalancer", "test-worker-lb")
+	if groups := alb.StringArray("securityGroups"); len(groups) != 1 || groups[0] != "sg-0a1b" {
+		t.Errorf("load balancer security groups = %v, want sg-0a1b", groups)
+	}
+	service := mocks.Resource(t, "aws:ecs/service:Service", "test-worker-ecs-service")
+	network := service.Inputs["networkConfiguration"].ObjectValue()
+	if groups := testutil.Strings(network, "securityGroups"); len(groups) != 1 || groups[0] != "sg-0c2d" {
+		t.Errorf("task security groups = %v, want sg-0c2d", groups)
+	}
+}
+
+func TestDeployRejectsUnknownSecurityGroup(t *testing.T) {
+	specs := []Spec{{Name: "worker", Port: 8080, Image: "registry.example.com/worker:1"}}
+	var returned error
+	_, _ = testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		platform, err := core.NewPlatform(ctx)
+		if err != nil {
+			return err
+		}
+		_, returned = Deploy(ctx, platform, specs, nil)
+		return returned
+	})
+	if returned == nil || !strings.Contains(returned.Error(), "securityGroupIds") {
+		t.Errorf("err = %v, want a hint at securityGroupIds", returned)
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"air-tek-iac/core"
+	"air-tek-iac/testutil"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func deployTestSpecs(ctx *pulumi.Context) error {
+	return deploySpecs(ctx, testSpecs)
+}
+
+func deploySpecs(ctx *pulumi.Context, specs []Spec) error {
+	platform, err := core.NewPlatform(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = Deploy(ctx, platform, specs, nil)
+	return err
+}
+
+// TestDeployReturnsServiceFailures checks that Deploy returns the error a
+// service constructor returns for args it rejects, named after the service.
+func TestDeployReturnsServiceFailures(t *testing.T) {
+	specs := append([]Spec(nil), testSpecs...)
+	specs[0].Cpu, specs[0].Memory = "300", "512"
+
+	var returned error
+	err := testutil.NewMocks().Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		returned = deploySpecs(ctx, specs)
+		return returned
+	})
+	if want := "service web-api: Fargate has no 300 cpu size"; returned == nil || !strings.Contains(returned.Error(), want) {
+		t.Errorf("returned %v, want it to contain %q", returned, want)
+	}
+	if err == nil {
+		t.Error("expected the run to fail")
+	}
+}
+
+// TestDeployWrapsCallFailures checks that failing provider functions are
+// returned by the constructors, named after the component that called them.
+func TestDeployWrapsCallFailures(t *testing.T) {
+	tests := map[string]string{
+		"aws:index/getAvailabilityZones:getAvailabilityZones": "network test: getting availability zones: injected",
+		"aws:index/getRegion:getRegion":                       "service web-api: getting region: injected",
+	}
+	for token, want := range tests {
+		t.Run(token, func(t *testing.T) {
+			failing := testutil.NewMocks()
+			failing.Failures = map[string]error{token: errors.New("injected")}
+			var returned error
+			err := failing.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+				returned = deployTestSpecs(ctx)
+				return returned
+			})
+			if returned == nil || !strings.Contains(returned.Error(), want) {
+				t.Errorf("returned %v, want it to contain %q", returned, want)
+			}
+			if err == nil {
+				t.Error("expected the run to fail")
+			}
+		})
+	}
+}
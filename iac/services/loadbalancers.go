@@ -0,0 +1,156 @@
+package services
+
+import (
+	"air-tek-iac/core"
+	"air-tek-iac/utils"
+	"fmt"
+	"sort"
+)
+
+// LoadBalancerSpec is one entry of the loadBalancers list in the stack config.
+// Services set loadBalancer to its name and routing to the requests they
+// serve to sit behind it instead of getting a load balancer of their own.
+type LoadBalancerSpec struct {
+	Name     string   `json:"name"`
+	Exposure Exposure `json:"exposure"`
+	// ListenerPort defaults to 80.
+	ListenerPort    int                       `json:"listenerPort"`
+	DomainName      string                    `json:"domainName"`
+	HostedZoneId    string                    `json:"hostedZoneId"`
+	DefaultResponse *utils.FixedResponseArgs  `json:"defaultResponse"`
+	FixedResponses  []utils.FixedResponseRule `json:"fixedResponses"`
+}
+
+func (l *LoadBalancerSpec) validate() error {
+	if !namePattern.MatchString(l.Name) {
+		return fmt.Errorf("load balancer name %q must be lowercase letters, digits and dashes", l.Name)
+	}
+	switch l.Exposure {
+	case PublicExposure, InternalExposure:
+	case "":
+		l.Exposure = InternalExposure
+	default:
+		return fmt.Errorf("load balancer %s: exposure must be %q or %q, got %q", l.Name, PublicExposure, InternalExposure, l.Exposure)
+	}
+	if l.ListenerPort == 0 {
+		l.ListenerPort = 80
+	}
+	if l.DomainName != "" && l.Exposure != PublicExposure {
+		return fmt.Errorf("load balancer %s: a domain name needs public exposure", l.Name)
+	}
+	args := utils.SharedLoadBalancerArgs{
+		DomainName:      l.DomainName,
+		HostedZoneId:    l.HostedZoneId,
+		DefaultResponse: l.DefaultResponse,
+		FixedResponses:  l.FixedResponses,
+	}
+	if err := args.Validate(); err != nil {
+		return fmt.Errorf("load balancer %s: %w", l.Name, err)
+	}
+	return nil
+}
+
+// ValidateLoadBalancers checks the shared load balancers and the routing of
+// the validated specs onto them: every service refers to a load balancer of
+// the same exposure, rule priorities are unique per load balancer, every
+// load balancer has at least one service and its security groups accept
+// its listener ports.
+func ValidateLoadBalancers(loadBalancers []LoadBalancerSpec, specs []Spec) error {
+	serviceNames := map[string]bool{}
+	for _, spec := range specs {
+		serviceNames[spec.Name] = true
+	}
+
+	byName := map[string]*LoadBalancerSpec{}
+	priorities := map[string]map[int]string{}
+	for i := range loadBalancers {
+		loadBalancer := &loadBalancers[i]
+		if err := loadBalancer.validate(); err != nil {
+			return err
+		}
+		if byName[loadBalancer.Name] != nil {
+			return fmt.Errorf("load balancer %s is defined more than once", loadBalancer.Name)
+		}
+		// Shared load balancers are named like the per-service ones, so
+		// they can't share a name with a service.
+		if serviceNames[loadBalancer.Name] {
+			return fmt.Errorf("load balancer %s has the name of a service", loadBalancer.Name)
+		}
+		byName[loadBalancer.Name] = loadBalancer
+		priorities[loadBalancer.Name] = map[int]string{}
+		for _, rule := range loadBalancer.FixedResponses {
+			priorities[loadBalancer.Name][rule.Priority] = "a fixed response"
+		}
+	}
+
+	routed := map[string]bool{}
+	// openPorts holds the ports the network's groups of the routed services
+	// accept, for load balancers whose services all use the network's
+	// groups. The load balancer gets every one of those groups.
+	openPorts := map[string]map[int]bool{}
+	for _, loadBalancer := range loadBalancers {
+		openPorts[loadBalancer.Name] = map[int]bool{}
+	}
+	for _, spec := range specs {
+		if spec.LoadBalancer == "" {
+			continue
+		}
+		loadBalancer := byName[spec.LoadBalancer]
+		if loadBalancer == nil {
+			return fmt.Errorf("service %s: unknown load balancer %s", spec.Name, spec.LoadBalancer)
+		}
+		if spec.Exposure != loadBalancer.Exposure {
+			return fmt.Errorf("service %s: exposure %s doesn't match the %s exposure of load balancer %s", spec.Name, spec.Exposure, loadBalancer.Exposure, loadBalancer.Name)
+		}
+		if user, ok := priorities[loadBalancer.Name][spec.Routing.Priority]; ok {
+			return fmt.Errorf("service %s: priority %d on load balancer %s is already used by %s", spec.Name, spec.Routing.Priority, loadBalancer.Name, user)
+		}
+		priorities[loadBalancer.Name][spec.Routing.Priority] = "service " + spec.Name
+		routed[loadBalancer.Name] = true
+
+		ports, ok := core.ServiceLoadBalancerPorts(spec.securityGroup())
+		if spec.SecurityGroupIds != nil || !ok {
+			// The ports of other groups aren't known.
+			openPorts[loadBalancer.Name] = nil
+		}
+		if openPorts[loadBalancer.Name] != nil {
+			for _, port := range ports {
+				openPorts[loadBalancer.Name][port] = true
+			}
+		}
+	}
+
+	for _, loadBalancer := range loadBalancers {
+		if !routed[loadBalancer.Name] {
+			return fmt.Errorf("load balancer %s has no services", loadBalancer.Name)
+		}
+		if err := loadBalancer.checkOpenPorts(openPorts[loadBalancer.Name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOpenPorts checks that the security groups accept traffic on the
+// listener ports, 443 as well with a domain name. A nil openPorts skips the
+// check.
+func (l *LoadBalancerSpec) checkOpenPorts(openPorts map[int]bool) error {
+	if openPorts == nil {
+		return nil
+	}
+	listenerPorts := []int{l.ListenerPort}
+	if l.DomainName != "" {
+		listenerPorts = append(listenerPorts, 443)
+	}
+	for _, port := range listenerPorts {
+		if !openPorts[port] {
+			var open []int
+			for port := range openPorts {
+				open = append(open, port)
+			}
+			sort.Ints(open)
+			return fmt.Errorf("load balancer %s: its security groups only accept ports %v, not listener port %d, set listenerPort to one of them", l.Name, open, port)
+		}
+	}
+	return nil
+}
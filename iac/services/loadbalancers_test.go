@@ -0,0 +1,98 @@
+package services
+
+import (
+	"air-tek-iac/utils"
+	"testing"
+)
+
+func TestValidateLoadBalancersDefaults(t *testing.T) {
+	specs := []Spec{{Name: "a", Port: 80, Image: "a", LoadBalancer: "shared", Routing: &utils.ListenerRuleArgs{Priority: 1, PathPatterns: []string{"/*"}}}}
+	loadBalancers := []LoadBalancerSpec{{Name: "shared"}}
+
+	specs, err := Validate(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateLoadBalancers(loadBalancers, specs); err != nil {
+		t.Fatal(err)
+	}
+	if loadBalancers[0].Exposure != InternalExposure || loadBalancers[0].ListenerPort != 80 {
+		t.Errorf("defaults = %s on port %d", loadBalancers[0].Exposure, loadBalancers[0].ListenerPort)
+	}
+}
+
+func TestValidateLoadBalancersRejectsInvalidRouting(t *testing.T) {
+	routing := func(priority int) *utils.ListenerRuleArgs {
+		return &utils.ListenerRuleArgs{Priority: priority, PathPatterns: []string{"/*"}}
+	}
+	shared := []LoadBalancerSpec{{Name: "shared"}}
+	tests := map[string]struct {
+		loadBalancers []LoadBalancerSpec
+		specs         []Spec
+	}{
+		"unknown load balancer": {shared, []Spec{
+			{Name: "a", Port: 80, Image: "a", LoadBalancer: "edge", Routing: routing(1)},
+		}},
+		"exposure mismatch": {shared, []Spec{
+			{Name: "a", Port: 80, Image: "a", Exposure: PublicExposure, LoadBalancer: "shared", Routing: routing(1)},
+		}},
+		"duplicate priority": {shared, []Spec{
+			{Name: "a", Port: 80, Image: "a", LoadBalancer: "shared", Routing: routing(1)},
+			{Name: "b", Port: 80, Image: "b", LoadBalancer: "shared", Routing: routing(1)},
+		}},
+		"fixed response priority": {[]LoadBalancerSpec{{Name: "shared", FixedResponses: []utils.FixedResponseRule{
+			{ListenerRuleArgs: *routing(1)},
+		}}}, []Spec{
+			{Name: "a", Port: 80, Image: "a", LoadBalancer: "shared", Routing: routing(1)},
+		}},
+		"no services": {shared, []Spec{{Name: "a", Port: 80, Image: "a"}}},
+		"service name": {[]LoadBalancerSpec{{Name: "a"}}, []Spec{
+			{Name: "a", Port: 80, Image: "a", LoadBalancer: "a", Routing: routing(1)},
+		}},
+		"listener port closed": {shared, []Spec{
+			{Name: "web-api", Port: 5000, Image: "a", LoadBalancer: "shared", Routing: routing(1)},
+		}},
+		"https port closed": {[]LoadBalancerSpec{{Name: "shared", Exposure: PublicExposure, ListenerPort: 5000, DomainName: "example.com", HostedZoneId: "Z1"}}, []Spec{
+			{Name: "web-api", Port: 5000, Image: "a", Exposure: PublicExposure, LoadBalancer: "shared", Routing: routing(1)},
+		}},
+		"internal domain": {[]LoadBalancerSpec{{Name: "shared", DomainName: "example.com", HostedZoneId: "Z1"}}, []Spec{
+			{Name: "a", Port: 80, Image: "a", LoadBalancer: "shared", Routing: routing(1)},
+		}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			specs, err := Validate(test.specs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ValidateLoadBalancers(test.loadBalancers, specs); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestValidateLoadBalancersListenerPorts(t *testing.T) {
+	routing := &utils.ListenerRuleArgs{Priority: 1, PathPatterns: []string{"/*"}}
+	tests := map[string]struct {
+		loadBalancer LoadBalancerSpec
+		spec         Spec
+	}{
+		"web-api group port": {LoadBalancerSpec{Name: "shared", ListenerPort: 5000},
+			Spec{Name: "web-api", Port: 5000, Image: "a", LoadBalancer: "shared", Routing: routing}},
+		"own security groups": {LoadBalancerSpec{Name: "shared"},
+			Spec{Name: "web-api", Port: 5000, Image: "a", LoadBalancer: "shared", Routing: routing,
+				SecurityGroupIds: &SecurityGroupIds{LoadBalancer: "sg-0a1b", Task: "sg-0c2d"}}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			specs, err := Validate([]Spec{test.spec})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ValidateLoadBalancers([]LoadBalancerSpec{test.loadBalancer}, specs); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
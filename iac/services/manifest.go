@@ -0,0 +1,257 @@
+package services
+
+import (
+	"air-tek-iac/utils"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Exposure string
+
+const (
+	// PublicExposure puts the service behind an internet-facing load balancer.
+	PublicExposure Exposure = "public"
+	// InternalExposure keeps the load balancer on private subnets.
+	InternalExposure Exposure = "internal"
+)
+
+// Spec is one entry of the services manifest in the stack config.
+type Spec struct {
+	Name            string                `json:"name"`
+	Dockerfile      string                `json:"dockerfile"`
+	BuildContext    string                `json:"buildContext"`
+	Image           string                `json:"image"`
+	ImageTag        string                `json:"imageTag"`
+	Port            int                   `json:"port"`
+	ListenerPort    int                   `json:"listenerPort"`
+	HealthCheckPath string                `json:"healthCheckPath"`
+	HealthCheck     utils.HealthCheckArgs `json:"healthCheck"`
+	Exposure        Exposure              `json:"exposure"`
+	SecurityGroup   string                `json:"securityGroup"`
+	// SecurityGroupIds, when set, puts the service in existing security
+	// groups instead of the network's, e.g. for a service the network has no
+	// groups for.
+	SecurityGroupIds *SecurityGroupIds      `json:"securityGroupIds"`
+	SubnetTier       utils.SubnetTier       `json:"subnetTier"`
+	AssignPublicIp   *bool                  `json:"assignPublicIp"`
+	Environment      map[string]string      `json:"environment"`
+	Cpu              string                 `json:"cpu"`
+	Memory           string                 `json:"memory"`
+	DesiredCount     int                    `json:"desiredCount"`
+	Autoscaling      *utils.AutoscalingArgs `json:"autoscaling"`
+	DomainName       string                 `json:"domainName"`
+	HostedZoneId     string                 `json:"hostedZoneId"`
+	// LoadBalancer names a shared load balancer of the loadBalancers list
+	// that routes the requests matching Routing to the service.
+	LoadBalancer string                   `json:"loadBalancer"`
+	Routing      *utils.ListenerRuleArgs  `json:"routing"`
+	Logs         *utils.LogArgs           `json:"logs"`
+	Registry     *utils.ECRRepositoryArgs `json:"registry"`
+}
+
+// SecurityGroupIds are the groups of a service's load balancer and tasks.
+// The task group has to accept the service port from the load balancer group.
+type SecurityGroupIds struct {
+	LoadBalancer string `json:"loadBalancer"`
+	Task         string `json:"task"`
+}
+
+// referencePattern matches ${<service>.url} inside environment values.
+var referencePattern = regexp.MustCompile(`\$\{([A-Za-z0-9-]+)\.([A-Za-z]+)\}`)
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+var securityGroupIdPattern = regexp.MustCompile(`^sg-[0-9a-f]+$`)
+
+// Dependencies returns the services this spec's environment refers to.
+func (s *Spec) Dependencies() []string {
+	seen := map[string]bool{}
+	var dependencies []string
+	for _, key := range sortedKeys(s.Environment) {
+		for _, match := range referencePattern.FindAllStringSubmatch(s.Environment[key], -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				dependencies = append(dependencies, match[1])
+			}
+		}
+	}
+	return dependencies
+}
+
+// Validate checks every spec on its own and that references between them
+// resolve, then returns the specs ordered so that each service comes after
+// the services it refers to. Reference cycles are reported as errors.
+func Validate(specs []Spec) ([]Spec, error) {
+	byName := map[string]*Spec{}
+	for i := range specs {
+		spec := &specs[i]
+		if err := spec.validate(); err != nil {
+			return nil, err
+		}
+		if byName[spec.Name] != nil {
+			return nil, fmt.Errorf("service %s is defined more than once", spec.Name)
+		}
+		byName[spec.Name] = spec
+	}
+
+	for i := range specs {
+		spec := &specs[i]
+		for _, key := range sortedKeys(spec.Environment) {
+			for _, match := range referencePattern.FindAllStringSubmatch(spec.Environment[key], -1) {
+				if byName[match[1]] == nil {
+					return nil, fmt.Errorf("service %s: environment %s refers to unknown service %s", spec.Name, key, match[1])
+				}
+				if match[2] != "url" {
+					return nil, fmt.Errorf("service %s: environment %s refers to %s.%s, only url can be referenced", spec.Name, key, match[1], match[2])
+				}
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	ordered := make([]Spec, 0, len(specs))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("services reference each other in a cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		spec := byName[name]
+		for _, dependency := range spec.Dependencies() {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		ordered = append(ordered, *spec)
+		return nil
+	}
+
+	for _, spec := range specs {
+		if err := visit(spec.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+func (s *Spec) validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("service name %q must be lowercase letters, digits and dashes", s.Name)
+	}
+	if s.Port <= 0 {
+		return fmt.Errorf("service %s: port is required", s.Name)
+	}
+	if s.Dockerfile == "" && s.Image == "" && s.ImageTag == "" {
+		return fmt.Errorf("service %s: one of dockerfile, image or imageTag is required", s.Name)
+	}
+	switch s.Exposure {
+	case PublicExposure, InternalExposure:
+	case "":
+		s.Exposure = InternalExposure
+	default:
+		return fmt.Errorf("service %s: exposure must be %q or %q, got %q", s.Name, PublicExposure, InternalExposure, s.Exposure)
+	}
+	if s.SubnetTier == "" {
+		s.SubnetTier = utils.PrivateSubnetTier
+	}
+	if _, err := s.SubnetTier.AssignPublicIp(s.AssignPublicIp); err != nil {
+		return fmt.Errorf("service %s: %w", s.Name, err)
+	}
+	if s.Cpu != "" || s.Memory != "" {
+		cpu, memory := s.Cpu, s.Memory
+		if cpu == "" {
+			cpu = utils.DefaultCpu
+		}
+		if memory == "" {
+			memory = utils.DefaultMemory
+		}
+		if err := utils.ValidateFargateSize(cpu, memory); err != nil {
+			return fmt.Errorf("service %s: %w", s.Name, err)
+		}
+	}
+	if s.HealthCheckPath != "" {
+		if s.HealthCheck.Path != "" && s.HealthCheck.Path != s.HealthCheckPath {
+			return fmt.Errorf("service %s: healthCheckPath %s and healthCheck.path %s disagree", s.Name, s.HealthCheckPath, s.HealthCheck.Path)
+		}
+		s.HealthCheck.Path = s.HealthCheckPath
+	}
+	if err := s.HealthCheck.Validate(); err != nil {
+		return fmt.Errorf("service %s: %w", s.Name, err)
+	}
+	if s.Autoscaling != nil {
+		if err := s.Autoscaling.Validate(); err != nil {
+			return fmt.Errorf("service %s: %w", s.Name, err)
+		}
+	}
+	if s.Logs != nil {
+		if err := s.Logs.Validate(); err != nil {
+			return fmt.Errorf("service %s: %w", s.Name, err)
+		}
+	}
+	if s.Registry != nil {
+		if err := s.Registry.Validate(); err != nil {
+			return fmt.Errorf("service %s: %w", s.Name, err)
+		}
+	}
+	if s.DomainName != "" && s.Exposure != PublicExposure {
+		return fmt.Errorf("service %s: a domain name needs public exposure", s.Name)
+	}
+	if s.SecurityGroupIds != nil {
+		if s.SecurityGroup != "" {
+			return fmt.Errorf("service %s: securityGroup and securityGroupIds can't be set together", s.Name)
+		}
+		for _, id := range []string{s.SecurityGroupIds.LoadBalancer, s.SecurityGroupIds.Task} {
+			if !securityGroupIdPattern.MatchString(id) {
+				return fmt.Errorf("service %s: securityGroupIds needs loadBalancer and task group ids, got %q", s.Name, id)
+			}
+		}
+	}
+	if (s.LoadBalancer == "") != (s.Routing == nil) {
+		return fmt.Errorf("service %s: loadBalancer and routing are set together", s.Name)
+	}
+	if s.LoadBalancer != "" {
+		if s.ListenerPort != 0 || s.DomainName != "" || s.HostedZoneId != "" {
+			return fmt.Errorf("service %s: listenerPort, domainName and hostedZoneId are set on load balancer %s", s.Name, s.LoadBalancer)
+		}
+		if err := s.Routing.Validate(); err != nil {
+			return fmt.Errorf("service %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// securityGroup is the network security group of the service, named after the
+// service unless set. With securityGroupIds it is the load balancer group id.
+func (s *Spec) securityGroup() string {
+	if s.SecurityGroupIds != nil {
+		return s.SecurityGroupIds.LoadBalancer
+	}
+	if s.SecurityGroup != "" {
+		return s.SecurityGroup
+	}
+	return s.Name
+}
+
+// configNamespace is the config namespace holding deploy-time overrides for
+// a service, e.g. webApi for web-api.
+func configNamespace(name string) string {
+	parts := strings.Split(name, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
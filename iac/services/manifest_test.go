@@ -0,0 +1,75 @@
+package services
+
+import (
+	"air-tek-iac/utils"
+	"strings"
+	"testing"
+)
+
+func TestValidateOrdersByReference(t *testing.T) {
+	specs := []Spec{
+		{Name: "web-ui", Port: 5000, Dockerfile: "ui/Dockerfile", Environment: map[string]string{"ApiAddress": "${web-api.url}/WeatherForecast"}},
+		{Name: "web-api", Port: 5000, Dockerfile: "api/Dockerfile"},
+	}
+
+	ordered, err := Validate(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ordered[0].Name != "web-api" || ordered[1].Name != "web-ui" {
+		t.Errorf("order = %s, %s", ordered[0].Name, ordered[1].Name)
+	}
+	if ordered[0].Exposure != InternalExposure {
+		t.Errorf("default exposure = %q", ordered[0].Exposure)
+	}
+}
+
+func TestValidateDetectsCycles(t *testing.T) {
+	specs := []Spec{
+		{Name: "a", Port: 80, Image: "a", Environment: map[string]string{"B": "${b.url}"}},
+		{Name: "b", Port: 80, Image: "b", Environment: map[string]string{"C": "${c.url}"}},
+		{Name: "c", Port: 80, Image: "c", Environment: map[string]string{"A": "${a.url}"}},
+	}
+
+	_, err := Validate(specs)
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("err = %v, want the cycle a -> b -> c -> a", err)
+	}
+}
+
+func TestValidateRejectsInvalidSpecs(t *testing.T) {
+	assignPublicIp := true
+	tests := map[string][]Spec{
+		"unknown reference":  {{Name: "a", Port: 80, Image: "a", Environment: map[string]string{"B": "${b.url}"}}},
+		"unknown attribute":  {{Name: "a", Port: 80, Image: "a"}, {Name: "b", Port: 80, Image: "b", Environment: map[string]string{"A": "${a.arn}"}}},
+		"duplicate name":     {{Name: "a", Port: 80, Image: "a"}, {Name: "a", Port: 80, Image: "a"}},
+		"missing port":       {{Name: "a", Image: "a"}},
+		"missing image":      {{Name: "a", Port: 80}},
+		"bad name":           {{Name: "Web_Api", Port: 80, Image: "a"}},
+		"bad exposure":       {{Name: "a", Port: 80, Image: "a", Exposure: "world"}},
+		"internal domain":    {{Name: "a", Port: 80, Image: "a", DomainName: "a.example.com"}},
+		"bad subnet tier":    {{Name: "a", Port: 80, Image: "a", SubnetTier: "dmz"}},
+		"bad fargate size":   {{Name: "a", Port: 80, Image: "a", Cpu: "256", Memory: "4096"}},
+		"bad memory":         {{Name: "a", Port: 80, Image: "a", Memory: "1GB"}},
+		"private public ip":  {{Name: "a", Port: 80, Image: "a", AssignPublicIp: &assignPublicIp}},
+		"bad health check":   {{Name: "a", Port: 80, Image: "a", HealthCheck: utils.HealthCheckArgs{Interval: 1}}},
+		"two health paths":   {{Name: "a", Port: 80, Image: "a", HealthCheckPath: "/a", HealthCheck: utils.HealthCheckArgs{Path: "/b"}}},
+		"group and ids":      {{Name: "a", Port: 80, Image: "a", SecurityGroup: "web-api", SecurityGroupIds: &SecurityGroupIds{LoadBalancer: "sg-1", Task: "sg-2"}}},
+		"missing task group": {{Name: "a", Port: 80, Image: "a", SecurityGroupIds: &SecurityGroupIds{LoadBalancer: "sg-1"}}},
+		"routing only":       {{Name: "a", Port: 80, Image: "a", Routing: &utils.ListenerRuleArgs{Priority: 1, PathPatterns: []string{"/*"}}}},
+		"shared listener":    {{Name: "a", Port: 80, Image: "a", ListenerPort: 8080, LoadBalancer: "shared", Routing: &utils.ListenerRuleArgs{Priority: 1, PathPatterns: []string{"/*"}}}},
+	}
+	for name, specs := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Validate(specs); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestConfigNamespace(t *testing.T) {
+	if got := configNamespace("web-api"); got != "webApi" {
+		t.Errorf("configNamespace(web-api) = %q", got)
+	}
+}
@@ -0,0 +1,273 @@
+// Package testutil runs components against Pulumi mocks so they can be
+// tested offline, and records every resource they register for assertions.
+package testutil
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	Project = "air-tek-iac"
+	Stack   = "test"
+)
+
+// DefaultConfig is the minimal stack configuration the components need.
+func DefaultConfig() map[string]string {
+	return map[string]string{
+		"aws:region":       "us-east-1",
+		"network:name":     "test",
+		"network:vpcRange": "10.1.0.0/16",
+	}
+}
+
+type Resource struct {
+	TypeToken string
+	Name      string
+	URN       string
+	Custom    bool
+	Inputs    resource.PropertyMap
+	// PropertyDependencies maps an input to the URNs of the resources its
+	// value comes from.
+	PropertyDependencies map[string][]string
+}
+
+// Mocks records registered resources and answers the provider functions the
+// components call.
+type Mocks struct {
+	AvailabilityZones []string
+	// Failures makes calling the functions with these tokens fail with the
+	// given error.
+	Failures map[string]error
+
+	mu        sync.Mutex
+	resources []Resource
+}
+
+func (m *Mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	registered := Resource{
+		TypeToken:            args.TypeToken,
+		Name:                 args.Name,
+		Custom:               args.Custom,
+		Inputs:               args.Inputs,
+		PropertyDependencies: map[string][]string{},
+	}
+	if rpc := args.RegisterRPC; rpc != nil {
+		registered.URN = newURN(rpc.GetParent(), args.TypeToken, args.Name)
+		for key, dependencies := range rpc.GetPropertyDependencies() {
+			registered.PropertyDependencies[key] = dependencies.GetUrns()
+		}
+	}
+	m.mu.Lock()
+	m.resources = append(m.resources, registered)
+	m.mu.Unlock()
+
+	id := args.Name + "-id"
+	outputs := args.Inputs.Copy()
+	outputs["arn"] = resource.NewStringProperty(fmt.Sprintf("arn:aws:mock:us-east-1:123456789012:%s/%s", args.TypeToken, args.Name))
+
+	switch args.TypeToken {
+	case "aws:elasticloadbalancingv2/loadBalancer:LoadBalancer":
+		outputs["dnsName"] = resource.NewStringProperty(args.Name + ".elb.amazonaws.com")
+		outputs["zoneId"] = resource.NewStringProperty("Z35SXDOTRQ7X7K")
+		outputs["arnSuffix"] = resource.NewStringProperty("app/" + args.Name + "/1234")
+	case "aws:elasticloadbalancingv2/targetGroup:TargetGroup":
+		outputs["arnSuffix"] = resource.NewStringProperty("targetgroup/" + args.Name + "/5678")
+	case "aws:ecr/repository:Repository":
+		outputs["repositoryUrl"] = resource.NewStringProperty("123456789012.dkr.ecr.us-east-1.amazonaws.com/" + args.Name)
+		outputs["registryId"] = resource.NewStringProperty("123456789012")
+		outputs["name"] = resource.NewStringProperty(args.Name)
+	case "aws:ecs/cluster:Cluster":
+		outputs["arn"] = resource.NewStringProperty("arn:aws:ecs:us-east-1:123456789012:cluster/" + args.Name)
+	case "aws:ecs/service:Service":
+		outputs["name"] = resource.NewStringProperty(args.Name)
+	case "aws:iam/role:Role", "aws:cloudwatch/logGroup:LogGroup":
+		outputs["name"] = resource.NewStringProperty(args.Name)
+	case "aws:acm/certificate:Certificate":
+		outputs["domainValidationOptions"] = resource.NewArrayProperty([]resource.PropertyValue{
+			resource.NewObjectProperty(resource.PropertyMap{
+				"domainName":          args.Inputs["domainName"],
+				"resourceRecordName":  resource.NewStringProperty("_validation." + args.Inputs["domainName"].StringValue()),
+				"resourceRecordType":  resource.NewStringProperty("CNAME"),
+				"resourceRecordValue": resource.NewStringProperty("_validation.acm-validations.aws"),
+			}),
+		})
+	case "aws:acm/certificateValidation:CertificateValidation":
+		outputs["certificateArn"] = args.Inputs["certificateArn"]
+	case "aws:route53/record:Record":
+		outputs["fqdn"] = args.Inputs["name"]
+	case "docker:index/image:Image":
+		outputs["imageName"] = args.Inputs["imageName"]
+	}
+
+	return id, outputs, nil
+}
+
+// newURN builds the URN the mock engine gives a resource.
+func newURN(parent, typeToken, name string) string {
+	var parentType tokens.Type
+	if parentURN := resource.URN(parent); parentURN != "" && parentURN.Type() != resource.RootStackType {
+		parentType = parentURN.QualifiedType()
+	}
+	return string(resource.NewURN(Stack, Project, parentType, tokens.Type(typeToken), tokens.QName(name)))
+}
+
+func (m *Mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	if err := m.Failures[args.Token]; err != nil {
+		return nil, err
+	}
+
+	switch args.Token {
+	case "aws:index/getAvailabilityZones:getAvailabilityZones":
+		names := make([]resource.PropertyValue, len(m.AvailabilityZones))
+		for i, name := range m.AvailabilityZones {
+			names[i] = resource.NewStringProperty(name)
+		}
+		return resource.PropertyMap{"names": resource.NewArrayProperty(names)}, nil
+	case "aws:index/getRegion:getRegion":
+		return resource.PropertyMap{
+			"name": resource.NewStringProperty("us-east-1"),
+			"id":   resource.NewStringProperty("us-east-1"),
+		}, nil
+	case "aws:index/getCallerIdentity:getCallerIdentity":
+		return resource.PropertyMap{
+			"accountId": resource.NewStringProperty("123456789012"),
+			"arn":       resource.NewStringProperty("arn:aws:iam::123456789012:user/test"),
+			"userId":    resource.NewStringProperty("AIDATEST"),
+		}, nil
+	case "aws:ecr/getCredentials:getCredentials":
+		token := base64.StdEncoding.EncodeToString([]byte("AWS:mock-password"))
+		return resource.PropertyMap{
+			"authorizationToken": resource.NewStringProperty(token),
+			"proxyEndpoint":      resource.NewStringProperty("https://123456789012.dkr.ecr.us-east-1.amazonaws.com"),
+		}, nil
+	}
+	return args.Args, nil
+}
+
+// Resources returns the recorded resources of the given type.
+func (m *Mocks) Resources(typeToken string) []Resource {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matches []Resource
+	for _, r := range m.resources {
+		if r.TypeToken == typeToken {
+			matches = append(matches, r)
+		}
+	}
+	return matches
+}
+
+// All returns every recorded resource.
+func (m *Mocks) All() []Resource {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Resource(nil), m.resources...)
+}
+
+// Resource returns the recorded resource with the given type and name.
+func (m *Mocks) Resource(t *testing.T, typeToken, name string) Resource {
+	t.Helper()
+	for _, r := range m.Resources(typeToken) {
+		if r.Name == name {
+			return r
+		}
+	}
+	t.Fatalf("no %s named %s was registered", typeToken, name)
+	return Resource{}
+}
+
+// NewMocks returns mocks for a region with three availability zones.
+func NewMocks() *Mocks {
+	return &Mocks{AvailabilityZones: []string{"us-east-1a", "us-east-1b", "us-east-1c"}}
+}
+
+// Run executes body against fresh mocks with the given stack configuration
+// and returns the mocks once every output has resolved.
+func Run(config map[string]string, body pulumi.RunFunc) (*Mocks, error) {
+	mocks := NewMocks()
+	return mocks, mocks.Run(config, body)
+}
+
+// Run executes body against these mocks, e.g. after setting Failures.
+func (m *Mocks) Run(config map[string]string, body pulumi.RunFunc) error {
+	return pulumi.RunErr(body, pulumi.WithMocks(Project, Stack, m), func(info *pulumi.RunInfo) {
+		info.Config = config
+	})
+}
+
+// StringValue returns a string input, or "" when it is unset.
+func (r Resource) StringValue(key string) string {
+	value, ok := r.Inputs[resource.PropertyKey(key)]
+	if !ok || !value.IsString() {
+		return ""
+	}
+	return value.StringValue()
+}
+
+// BoolValue returns a bool input, or false when it is unset.
+func (r Resource) BoolValue(key string) bool {
+	value, ok := r.Inputs[resource.PropertyKey(key)]
+	if !ok || !value.IsBool() {
+		return false
+	}
+	return value.BoolValue()
+}
+
+// StringArray returns a string array input.
+func (r Resource) StringArray(key string) []string {
+	value, ok := r.Inputs[resource.PropertyKey(key)]
+	if !ok || !value.IsArray() {
+		return nil
+	}
+	var values []string
+	for _, item := range value.ArrayValue() {
+		if item.IsString() {
+			values = append(values, item.StringValue())
+		}
+	}
+	return values
+}
+
+// Tags returns the tags input of the resource.
+func (r Resource) Tags() map[string]string {
+	value, ok := r.Inputs["tags"]
+	if !ok || !value.IsObject() {
+		return nil
+	}
+	tags := map[string]string{}
+	for key, tag := range value.ObjectValue() {
+		if tag.IsString() {
+			tags[string(key)] = tag.StringValue()
+		}
+	}
+	return tags
+}
+
+// Objects returns an array-of-objects input, e.g. security group rules.
+func (r Resource) Objects(key string) []resource.PropertyMap {
+	value, ok := r.Inputs[resource.PropertyKey(key)]
+	if !ok || !value.IsArray() {
+		return nil
+	}
+	var objects []resource.PropertyMap
+	for _, item := range value.ArrayValue() {
+		if item.IsObject() {
+			objects = append(objects, item.ObjectValue())
+		}
+	}
+	return objects
+}
+
+// Strings returns the string array held under key in an object input.
+func Strings(object resource.PropertyMap, key string) []string {
+	return Resource{Inputs: object}.StringArray(key)
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/appautoscaling"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// AutoscalingArgs configures target tracking for an ECS service. Each target
+// that is left at zero gets no policy.
+type AutoscalingArgs struct {
+	MinCount int `json:"minCount"`
+	MaxCount int `json:"maxCount"`
+	// CpuTarget and MemoryTarget are average utilisation percentages.
+	CpuTarget    float64 `json:"cpuTarget"`
+	MemoryTarget float64 `json:"memoryTarget"`
+	// RequestCountTarget is the number of load balancer requests per task.
+	RequestCountTarget float64 `json:"requestCountTarget"`
+	ScaleInCooldown    int     `json:"scaleInCooldown"`
+	ScaleOutCooldown   int     `json:"scaleOutCooldown"`
+}
+
+func (a *AutoscalingArgs) Validate() error {
+	if a.MinCount < 1 {
+		return fmt.Errorf("autoscaling minCount must be at least 1, got %d", a.MinCount)
+	}
+	if a.MaxCount < a.MinCount {
+		return fmt.Errorf("autoscaling maxCount %d is below minCount %d", a.MaxCount, a.MinCount)
+	}
+	if a.CpuTarget < 0 || a.CpuTarget > 100 || a.MemoryTarget < 0 || a.MemoryTarget > 100 {
+		return fmt.Errorf("autoscaling cpuTarget and memoryTarget are percentages between 0 and 100")
+	}
+	if a.RequestCountTarget < 0 {
+		return fmt.Errorf("autoscaling requestCountTarget must not be negative")
+	}
+	if a.CpuTarget == 0 && a.MemoryTarget == 0 && a.RequestCountTarget == 0 {
+		return fmt.Errorf("autoscaling needs at least one of cpuTarget, memoryTarget or requestCountTarget")
+	}
+	return nil
+}
+
+// newServiceAutoscaling registers the service as a scalable target and adds a
+// target tracking policy for every configured metric. resourceLabel
+// identifies the load balancer and target group for request count scaling.
+func newServiceAutoscaling(ctx *pulumi.Context, name string, args *AutoscalingArgs, clusterArn pulumi.StringInput, serviceName pulumi.StringInput, resourceLabel pulumi.StringInput, parent pulumi.Resource) error {
+	resourceId := pulumi.All(clusterArn, serviceName).ApplyT(func(values []interface{}) string {
+		clusterArn := values[0].(string)
+		clusterName := clusterArn[strings.LastIndex(clusterArn, "/")+1:]
+		return "service/" + clusterName + "/" + values[1].(string)
+	}).(pulumi.StringOutput)
+
+	target, err := appautoscaling.NewTarget(ctx, name+"-scaling-target", &appautoscaling.TargetArgs{
+		MinCapacity:       pulumi.Int(args.MinCount),
+		MaxCapacity:       pulumi.Int(args.MaxCount),
+		ResourceId:        resourceId,
+		ScalableDimension: pulumi.String("ecs:service:DesiredCount"),
+		ServiceNamespace:  pulumi.String("ecs"),
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", name+"-scaling-target", err)
+	}
+
+	policies := []struct {
+		suffix      string
+		metricType  string
+		targetValue float64
+	}{
+		{"cpu", "ECSServiceAverageCPUUtilization", args.CpuTarget},
+		{"memory", "ECSServiceAverageMemoryUtilization", args.MemoryTarget},
+		{"request-count", "ALBRequestCountPerTarget", args.RequestCountTarget},
+	}
+
+	for _, policy := range policies {
+		if policy.targetValue == 0 {
+			continue
+		}
+
+		metric := &appautoscaling.PolicyTargetTrackingScalingPolicyConfigurationPredefinedMetricSpecificationArgs{
+			PredefinedMetricType: pulumi.String(policy.metricType),
+		}
+		if policy.metricType == "ALBRequestCountPerTarget" {
+			metric.ResourceLabel = resourceLabel.ToStringOutput().ToStringPtrOutput()
+		}
+
+		scaling := &appautoscaling.PolicyTargetTrackingScalingPolicyConfigurationArgs{
+			TargetValue:                   pulumi.Float64(policy.targetValue),
+			PredefinedMetricSpecification: metric,
+		}
+		if args.ScaleInCooldown > 0 {
+			scaling.ScaleInCooldown = pulumi.Int(args.ScaleInCooldown)
+		}
+		if args.ScaleOutCooldown > 0 {
+			scaling.ScaleOutCooldown = pulumi.Int(args.ScaleOutCooldown)
+		}
+
+		_, err = appautoscaling.NewPolicy(ctx, name+"-"+policy.suffix+"-scaling-policy", &appautoscaling.PolicyArgs{
+			PolicyType:                               pulumi.String("TargetTrackingScaling"),
+			ResourceId:                               target.ResourceId,
+			ScalableDimension:                        target.ScalableDimension,
+			ServiceNamespace:                         target.ServiceNamespace,
+			TargetTrackingScalingPolicyConfiguration: scaling,
+		}, pulumi.Parent(parent))
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", name+"-"+policy.suffix+"-scaling-policy", err)
+		}
+	}
+
+	return nil
+}
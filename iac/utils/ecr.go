@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -13,52 +14,220 @@ import (
 type ECRRepository struct {
 	pulumi.ResourceState
 
-	RepositoryUrl pulumi.StringOutput `pulumi:"user"`
+	RepositoryUrl pulumi.StringOutput `pulumi:"repositoryUrl"`
 	User          pulumi.StringOutput `pulumi:"user"`
 	Pass          pulumi.StringOutput `pulumi:"pass"`
 }
 
-func NewECRRepository(ctx *pulumi.Context, name string, opts ...pulumi.ResourceOption) (*ECRRepository, error) {
+// ECRRepositoryArgs hardens a service's repository. The zero value scans on
+// push, keeps tags immutable, encrypts with AES256, keeps the last 30 images
+// and expires untagged images after 14 days.
+type ECRRepositoryArgs struct {
+	// ScanOnPush is on unless set to false.
+	ScanOnPush *bool `json:"scanOnPush"`
+	// ImmutableTags rejects pushing a tag that already exists. It is on
+	// unless set to false.
+	ImmutableTags *bool `json:"immutableTags"`
+	// KmsKeyId encrypts images with a customer managed key instead of
+	// AES256.
+	KmsKeyId string `json:"kmsKeyId"`
+	// KeepImages is how many of the most recent tagged images the lifecycle
+	// policy keeps.
+	KeepImages int `json:"keepImages"`
+	// UntaggedExpiryDays is how long untagged images are kept.
+	UntaggedExpiryDays int `json:"untaggedExpiryDays"`
+	// PullAccountIds lists other accounts allowed to pull images, e.g. the
+	// accounts images are promoted to.
+	PullAccountIds []string `json:"pullAccountIds"`
+}
+
+const (
+	defaultKeepImages         = 30
+	defaultUntaggedExpiryDays = 14
+)
+
+func (a *ECRRepositoryArgs) Validate() error {
+	if a.KeepImages < 0 {
+		return fmt.Errorf("registry keepImages must not be negative, got %d", a.KeepImages)
+	}
+	if a.UntaggedExpiryDays < 0 {
+		return fmt.Errorf("registry untaggedExpiryDays must not be negative, got %d", a.UntaggedExpiryDays)
+	}
+	return validateAccountIds("registry pullAccountIds", a.PullAccountIds)
+}
+
+// withDefaults returns a copy of the args with every unset field defaulted.
+func (a *ECRRepositoryArgs) withDefaults() ECRRepositoryArgs {
+	var args ECRRepositoryArgs
+	if a != nil {
+		args = *a
+	}
+	enabled := true
+	if args.ScanOnPush == nil {
+		args.ScanOnPush = &enabled
+	}
+	if args.ImmutableTags == nil {
+		args.ImmutableTags = &enabled
+	}
+	if args.KeepImages == 0 {
+		args.KeepImages = defaultKeepImages
+	}
+	if args.UntaggedExpiryDays == 0 {
+		args.UntaggedExpiryDays = defaultUntaggedExpiryDays
+	}
+	return args
+}
+
+type lifecyclePolicy struct {
+	Rules []lifecycleRule `json:"rules"`
+}
+
+type lifecycleRule struct {
+	RulePriority int                `json:"rulePriority"`
+	Description  string             `json:"description"`
+	Selection    lifecycleSelection `json:"selection"`
+	Action       lifecycleAction    `json:"action"`
+}
+
+type lifecycleSelection struct {
+	TagStatus      string   `json:"tagStatus"`
+	TagPatternList []string `json:"tagPatternList,omitempty"`
+	CountType      string   `json:"countType"`
+	CountUnit      string   `json:"countUnit,omitempty"`
+	CountNumber    int      `json:"countNumber"`
+}
+
+type lifecycleAction struct {
+	Type string `json:"type"`
+}
+
+// lifecyclePolicyDocument expires untagged images after a number of days and
+// tagged images beyond the most recent ones, so untagged images never count
+// toward the tagged images that are kept.
+func lifecyclePolicyDocument(args ECRRepositoryArgs) (string, error) {
+	document, err := json.Marshal(lifecyclePolicy{Rules: []lifecycleRule{
+		{
+			RulePriority: 1,
+			Description:  fmt.Sprintf("Expire untagged images after %d days", args.UntaggedExpiryDays),
+			Selection:    lifecycleSelection{TagStatus: "untagged", CountType: "sinceImagePushed", CountUnit: "days", CountNumber: args.UntaggedExpiryDays},
+			Action:       lifecycleAction{Type: "expire"},
+		},
+		{
+			RulePriority: 2,
+			Description:  fmt.Sprintf("Keep the last %d tagged images", args.KeepImages),
+			Selection:    lifecycleSelection{TagStatus: "tagged", TagPatternList: []string{"*"}, CountType: "imageCountMoreThan", CountNumber: args.KeepImages},
+			Action:       lifecycleAction{Type: "expire"},
+		},
+	}})
+	if err != nil {
+		return "", err
+	}
+	return string(document), nil
+}
+
+// NewECRRepository creates a repository hardened by args, which may be nil.
+// Whether the repository is deleted together with its images is decided per
+// stack by ecr:forceDelete, off by default.
+func NewECRRepository(ctx *pulumi.Context, name string, args *ECRRepositoryArgs, opts ...pulumi.ResourceOption) (_ *ECRRepository, err error) {
 	var resource ECRRepository
 
-	config := config.New(ctx, "network")
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("ecr repository %s: %w", name, err)
+		}
+	}()
 
-	err := ctx.RegisterComponentResource("air-tek:infra:ecr", name, &resource, opts...)
+	if args != nil {
+		if err := args.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	err = ctx.RegisterComponentResource("air-tek:infra:ecr", name, &resource, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	hardening := args.withDefaults()
+
+	tagMutability := "MUTABLE"
+	if *hardening.ImmutableTags {
+		tagMutability = "IMMUTABLE"
+	}
+	encryption := ecr.RepositoryEncryptionConfigurationArgs{
+		EncryptionType: pulumi.String("AES256"),
+	}
+	if hardening.KmsKeyId != "" {
+		encryption = ecr.RepositoryEncryptionConfigurationArgs{
+			EncryptionType: pulumi.String("KMS"),
+			KmsKey:         pulumi.String(hardening.KmsKeyId),
+		}
+	}
+
 	repo, err := ecr.NewRepository(ctx, name, &ecr.RepositoryArgs{
-		ForceDelete: pulumi.Bool(true),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
+		ForceDelete:        pulumi.Bool(config.New(ctx, "ecr").GetBool("forceDelete")),
+		ImageTagMutability: pulumi.String(tagMutability),
+		ImageScanningConfiguration: &ecr.RepositoryImageScanningConfigurationArgs{
+			ScanOnPush: pulumi.Bool(*hardening.ScanOnPush),
 		},
+		EncryptionConfigurations: ecr.RepositoryEncryptionConfigurationArray{encryption},
 	}, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", name, err)
+	}
+
+	policy, err := lifecyclePolicyDocument(hardening)
 	if err != nil {
 		return nil, err
 	}
+	_, err = ecr.NewLifecyclePolicy(ctx, name+"-lifecycle-policy", &ecr.LifecyclePolicyArgs{
+		Repository: repo.Name,
+		Policy:     pulumi.String(policy),
+	}, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", name+"-lifecycle-policy", err)
+	}
+
+	if len(hardening.PullAccountIds) > 0 {
+		policy, err := pullPolicyDocument(hardening.PullAccountIds)
+		if err != nil {
+			return nil, err
+		}
+		_, err = ecr.NewRepositoryPolicy(ctx, name+"-policy", &ecr.RepositoryPolicyArgs{
+			Repository: repo.Name,
+			Policy:     pulumi.String(policy),
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", name+"-policy", err)
+		}
+	}
 
-	creds := repo.RegistryId.ApplyT(func(registryId string) ([]string, error) {
+	// The decoded token is a registry password, keep it encrypted in state
+	// and out of logs wherever it flows.
+	creds := pulumi.ToSecret(repo.RegistryId.ApplyT(func(registryId string) ([]string, error) {
 		creds, err := ecr.GetCredentials(ctx, &ecr.GetCredentialsArgs{
 			RegistryId: registryId,
 		})
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("ecr repository %s: getting credentials: %w", name, err)
 		}
 		data, err := base64.StdEncoding.DecodeString(creds.AuthorizationToken)
 		if err != nil {
-			fmt.Println("error:", err)
-			return nil, err
+			return nil, fmt.Errorf("ecr repository %s: decoding credentials: %w", name, err)
 		}
 
-		return strings.Split(string(data), ":"), nil
-	}).(pulumi.StringArrayOutput)
+		return strings.SplitN(string(data), ":", 2), nil
+	})).(pulumi.StringArrayOutput)
 
 	resource.RepositoryUrl = repo.RepositoryUrl
 	resource.User = creds.Index(pulumi.Int(0))
 	resource.Pass = creds.Index(pulumi.Int(1))
 
+	ctx.RegisterResourceOutputs(&resource, pulumi.Map{
+		"repositoryUrl": repo.RepositoryUrl,
+		"user":          resource.User,
+		"pass":          resource.Pass,
+	})
+
 	return &resource, nil
 }
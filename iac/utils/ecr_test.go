@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"air-tek-iac/testutil"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestECRRepository(t *testing.T) {
+	var user, pass, url string
+	var registry *ECRRepository
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		var err error
+		registry, err = NewECRRepository(ctx, "test-ecr", nil)
+		if err != nil {
+			return err
+		}
+		pulumi.All(registry.User, registry.Pass, registry.RepositoryUrl).ApplyT(func(values []interface{}) string {
+			user, pass, url = values[0].(string), values[1].(string), values[2].(string)
+			return url
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repos := mocks.Resources("aws:ecr/repository:Repository"); len(repos) != 1 {
+		t.Fatalf("got %d repositories, want 1", len(repos))
+	}
+	if user != "AWS" || pass != "mock-password" {
+		t.Errorf("credentials = %q/%q", user, pass)
+	}
+	if !pulumi.IsSecret(registry.User) || !pulumi.IsSecret(registry.Pass) {
+		t.Error("registry credentials should be secret")
+	}
+	if url != "123456789012.dkr.ecr.us-east-1.amazonaws.com/test-ecr" {
+		t.Errorf("repository url = %q", url)
+	}
+
+	repo := mocks.Resource(t, "aws:ecr/repository:Repository", "test-ecr")
+	if repo.BoolValue("forceDelete") {
+		t.Error("forceDelete should be off unless the stack enables it")
+	}
+	if mutability := repo.StringValue("imageTagMutability"); mutability != "IMMUTABLE" {
+		t.Errorf("tag mutability = %q, want IMMUTABLE", mutability)
+	}
+	if !repo.Inputs["imageScanningConfiguration"].ObjectValue()["scanOnPush"].BoolValue() {
+		t.Error("scan on push should be on by default")
+	}
+	if encryption := repo.Objects("encryptionConfigurations"); len(encryption) != 1 || encryption[0]["encryptionType"].StringValue() != "AES256" {
+		t.Errorf("encryption = %v, want AES256", encryption)
+	}
+
+	lifecycle := mocks.Resource(t, "aws:ecr/lifecyclePolicy:LifecyclePolicy", "test-ecr-lifecycle-policy")
+	if lifecycle.StringValue("repository") != "test-ecr" {
+		t.Errorf("lifecycle policy repository = %q", lifecycle.StringValue("repository"))
+	}
+	var policy lifecyclePolicy
+	if err := json.Unmarshal([]byte(lifecycle.StringValue("policy")), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Rules) != 2 {
+		t.Fatalf("got %d lifecycle rules, want 2", len(policy.Rules))
+	}
+	if untagged := policy.Rules[0].Selection; untagged.TagStatus != "untagged" || untagged.CountNumber != 14 {
+		t.Errorf("untagged rule = %+v", untagged)
+	}
+	if keep := policy.Rules[1].Selection; keep.TagStatus != "tagged" || len(keep.TagPatternList) != 1 || keep.TagPatternList[0] != "*" || keep.CountNumber != 30 {
+		t.Errorf("keep rule = %+v", keep)
+	}
+}
+
+func TestECRRepositoryArgs(t *testing.T) {
+	config := testutil.DefaultConfig()
+	config["ecr:forceDelete"] = "true"
+	disabled := false
+	mocks, err := testutil.Run(config, func(ctx *pulumi.Context) error {
+		_, err := NewECRRepository(ctx, "test-ecr", &ECRRepositoryArgs{
+			ScanOnPush:         &disabled,
+			ImmutableTags:      &disabled,
+			KmsKeyId:           "arn:aws:kms:us-east-1:123456789012:key/images",
+			KeepImages:         5,
+			UntaggedExpiryDays: 1,
+			PullAccountIds:     []string{"210987654321"},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := mocks.Resource(t, "aws:ecr/repository:Repository", "test-ecr")
+	if !repo.BoolValue("forceDelete") {
+		t.Error("ecr:forceDelete should enable forceDelete")
+	}
+	if mutability := repo.StringValue("imageTagMutability"); mutability != "MUTABLE" {
+		t.Errorf("tag mutability = %q, want MUTABLE", mutability)
+	}
+	if repo.Inputs["imageScanningConfiguration"].ObjectValue()["scanOnPush"].BoolValue() {
+		t.Error("scan on push should be off")
+	}
+	encryption := repo.Objects("encryptionConfigurations")
+	if len(encryption) != 1 || encryption[0]["encryptionType"].StringValue() != "KMS" || encryption[0]["kmsKey"].StringValue() != "arn:aws:kms:us-east-1:123456789012:key/images" {
+		t.Errorf("encryption = %v, want the KMS key", encryption)
+	}
+
+	var policy lifecyclePolicy
+	lifecycle := mocks.Resource(t, "aws:ecr/lifecyclePolicy:LifecyclePolicy", "test-ecr-lifecycle-policy")
+	if err := json.Unmarshal([]byte(lifecycle.StringValue("policy")), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if policy.Rules[0].Selection.CountNumber != 1 || policy.Rules[1].Selection.CountNumber != 5 {
+		t.Errorf("lifecycle rules = %+v", policy.Rules)
+	}
+
+	pull := mocks.Resource(t, "aws:ecr/repositoryPolicy:RepositoryPolicy", "test-ecr-policy")
+	if !strings.Contains(pull.StringValue("policy"), "arn:aws:iam::210987654321:root") {
+		t.Errorf("repository policy %s does not grant the pull account", pull.StringValue("policy"))
+	}
+}
+
+func TestECRRepositoryRejectsArgsBeforeRegistering(t *testing.T) {
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewECRRepository(ctx, "test-ecr", &ECRRepositoryArgs{KeepImages: -1})
+		return err
+	})
+	if err == nil {
+		t.Fatal("expected an error for negative keepImages")
+	}
+	if components := mocks.Resources("air-tek:infra:ecr"); len(components) != 0 {
+		t.Errorf("registered %d ecr components for invalid args, want none", len(components))
+	}
+}
+
+func TestServiceRegistryArgs(t *testing.T) {
+	immutable := true
+	built, err := serviceRegistryArgs(&FargateServiceArgs{Dockerfile: "Dockerfile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *built.ImmutableTags {
+		t.Error("services built from a Dockerfile need mutable tags")
+	}
+
+	prebuilt, err := serviceRegistryArgs(&FargateServiceArgs{ImageTag: "1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prebuilt != nil {
+		t.Errorf("pre-built images should keep the repository defaults, got %+v", prebuilt)
+	}
+
+	_, err = serviceRegistryArgs(&FargateServiceArgs{Dockerfile: "Dockerfile", Registry: &ECRRepositoryArgs{ImmutableTags: &immutable}})
+	if err == nil {
+		t.Error("expected an error for immutable tags on a Dockerfile build")
+	}
+}
+
+// TestECRPasswordIsNeverPlaintext builds a service image, which hands the
+// registry credentials to the docker provider, and checks that no resource
+// receives the password unencrypted.
+func TestECRPasswordIsNeverPlaintext(t *testing.T) {
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewFargateService(ctx, &FargateServiceArgs{
+			Name:          "svc",
+			NetworkName:   "test",
+			EcsClusterArn: pulumi.String("arn:aws:ecs:us-east-1:123456789012:cluster/test-cluster"),
+			Dockerfile:    "svc/Dockerfile",
+			Port:          8080,
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image := mocks.Resource(t, "docker:index/image:Image", "svc")
+	password := image.Inputs["registry"].ObjectValue()["password"]
+	if !password.IsSecret() {
+		t.Errorf("docker registry password = %v, want a secret", password)
+	}
+
+	for _, r := range mocks.All() {
+		for key, value := range r.Inputs {
+			if path, found := findPlaintext(value, "mock-password", string(key)); found {
+				t.Errorf("%s %s: %s holds the registry password in plaintext", r.TypeToken, r.Name, path)
+			}
+		}
+	}
+}
+
+// findPlaintext looks for secret outside of secret values and returns where
+// it was found.
+func findPlaintext(value resource.PropertyValue, secret string, path string) (string, bool) {
+	switch {
+	case value.IsSecret():
+		return "", false
+	case value.IsString():
+		return path, strings.Contains(value.StringValue(), secret)
+	case value.IsArray():
+		for i, item := range value.ArrayValue() {
+			if found, ok := findPlaintext(item, secret, fmt.Sprintf("%s[%d]", path, i)); ok {
+				return found, true
+			}
+		}
+	case value.IsObject():
+		for key, item := range value.ObjectValue() {
+			if found, ok := findPlaintext(item, secret, path+"."+string(key)); ok {
+				return found, true
+			}
+		}
+	case value.IsOutput():
+		if output := value.OutputValue(); !output.Secret {
+			return findPlaintext(output.Element, secret, path)
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"air-tek-iac/testutil"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	getRegionToken         = "aws:index/getRegion:getRegion"
+	getCallerIdentityToken = "aws:index/getCallerIdentity:getCallerIdentity"
+)
+
+func testServiceArgs() *FargateServiceArgs {
+	return &FargateServiceArgs{
+		Name:          "svc",
+		NetworkName:   "test",
+		VpcId:         pulumi.String("vpc-1"),
+		EcsClusterArn: pulumi.String("arn:aws:ecs:us-east-1:123456789012:cluster/test-cluster"),
+		Image:         "registry.example.com/svc:1",
+		Port:          8080,
+	}
+}
+
+// TestConstructorsReturnFailures injects the failures a constructor sees
+// while it runs, failing provider functions and invalid args, and checks
+// that the constructor returns them named after its component.
+func TestConstructorsReturnFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		failing   string
+		construct func(ctx *pulumi.Context) error
+		want      string
+	}{
+		{
+			name: "load balancer without hosted zone",
+			construct: func(ctx *pulumi.Context) error {
+				_, err := NewLoadBalancer(ctx, &LoadBalancerArgs{LoadBalancerName: "lb", DomainName: "app.example.com"})
+				return err
+			},
+			want: "load balancer lb: a hosted zone id is required",
+		},
+		{
+			name: "load balancer health check",
+			construct: func(ctx *pulumi.Context) error {
+				_, err := NewLoadBalancer(ctx, &LoadBalancerArgs{LoadBalancerName: "lb", HealthCheck: HealthCheckArgs{Path: "health"}})
+				return err
+			},
+			want: "load balancer lb: health check path",
+		},
+		{
+			name: "shared load balancer default response",
+			construct: func(ctx *pulumi.Context) error {
+				_, err := NewSharedLoadBalancer(ctx, &SharedLoadBalancerArgs{LoadBalancerName: "shared", DefaultResponse: &FixedResponseArgs{StatusCode: 301}})
+				return err
+			},
+			want: "load balancer shared: fixed response status code must be 2XX, 4XX or 5XX, got 301",
+		},
+		{
+			name: "load balancer route rule",
+			construct: func(ctx *pulumi.Context) error {
+				lb, err := NewSharedLoadBalancer(ctx, &SharedLoadBalancerArgs{LoadBalancerName: "shared", VpcId: pulumi.String("vpc-1")})
+				if err != nil {
+					return err
+				}
+				_, err = NewLoadBalancerRoute(ctx, &LoadBalancerRouteArgs{RouteName: "route", LoadBalancer: lb, TargetPort: 80})
+				return err
+			},
+			want: "load balancer route route: rule priority must be between 1 and 50000, got 0",
+		},
+		{
+			name: "ecr repository args",
+			construct: func(ctx *pulumi.Context) error {
+				_, err := NewECRRepository(ctx, "repo", &ECRRepositoryArgs{KeepImages: -1})
+				return err
+			},
+			want: "ecr repository repo: registry keepImages must not be negative",
+		},
+		{
+			name: "ecr registry args",
+			construct: func(ctx *pulumi.Context) error {
+				_, err := NewECRRegistry(ctx, "registry", &ECRRegistryArgs{Replication: []ReplicationRule{{}}})
+				return err
+			},
+			want: "ecr registry registry: ecr:replication",
+		},
+		{
+			name:    "ecr registry region",
+			failing: getRegionToken,
+			construct: func(ctx *pulumi.Context) error {
+				_, err := NewECRRegistry(ctx, "registry", &ECRRegistryArgs{AllowReplicationFrom: []string{"210987654321"}})
+				return err
+			},
+			want: "ecr registry registry: getting region: injected",
+		},
+		{
+			name:    "ecr registry caller identity",
+			failing: getCallerIdentityToken,
+			construct: func(ctx *pulumi.Context) error {
+				_, err := NewECRRegistry(ctx, "registry", &ECRRegistryArgs{AllowReplicationFrom: []string{"210987654321"}})
+				return err
+			},
+			want: "ecr registry registry: getting caller identity: injected",
+		},
+		{
+			name: "fargate service size",
+			construct: func(ctx *pulumi.Context) error {
+				args := testServiceArgs()
+				args.Cpu = "300"
+				_, err := NewFargateService(ctx, args)
+				return err
+			},
+			want: "service svc: Fargate has no 300 cpu size",
+		},
+		{
+			name: "fargate service health check",
+			construct: func(ctx *pulumi.Context) error {
+				args := testServiceArgs()
+				args.HealthCheck = HealthCheckArgs{Interval: 1}
+				_, err := NewFargateService(ctx, args)
+				return err
+			},
+			want: "service svc: load balancer test-svc-lb: health check interval",
+		},
+		{
+			name:    "fargate service region",
+			failing: getRegionToken,
+			construct: func(ctx *pulumi.Context) error {
+				_, err := NewFargateService(ctx, testServiceArgs())
+				return err
+			},
+			want: "service svc: getting region: injected",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mocks := testutil.NewMocks()
+			if test.failing != "" {
+				mocks.Failures = map[string]error{test.failing: errors.New("injected")}
+			}
+			var returned error
+			err := mocks.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+				returned = test.construct(ctx)
+				return returned
+			})
+			if returned == nil || !strings.Contains(returned.Error(), test.want) {
+				t.Errorf("returned %v, want it to contain %q", returned, test.want)
+			}
+			if err == nil {
+				t.Error("expected the run to fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ecs"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type FargateService struct {
+	pulumi.ResourceState
+
+	Url          pulumi.StringOutput `pulumi:"Url"`
+	LogGroupName pulumi.StringOutput `pulumi:"LogGroupName"`
+}
+
+type FargateServiceArgs struct {
+	Name                       string
+	NetworkName                string
+	VpcId                      pulumi.StringInput
+	EcsClusterArn              pulumi.StringInput
+	LoadBalancerSubnets        pulumi.StringArrayInput
+	LoadBalancerSecurityGroups pulumi.StringArrayInput
+	Subnets                    pulumi.StringArrayInput
+	SecurityGroups             pulumi.StringArrayInput
+	// SubnetTier is the tier of Subnets, private when empty. Tasks get a
+	// public IP only in public subnets.
+	SubnetTier SubnetTier
+	// AssignPublicIp, when set, has to agree with SubnetTier.
+	AssignPublicIp *bool
+	// Internal places the load balancer on private addresses only.
+	Internal bool
+	// SharedLoadBalancer, when set, routes the requests matching Rule to the
+	// service instead of giving it a load balancer of its own. The
+	// LoadBalancer*, Internal, ListenerPort and domain fields are then unused.
+	SharedLoadBalancer *SharedLoadBalancer
+	Rule               ListenerRuleArgs
+	// Dockerfile is built from BuildContext when neither Image nor ImageTag
+	// is set.
+	Dockerfile   string
+	BuildContext string
+	Image        string
+	ImageTag     string
+	Port         int
+	ListenerPort int
+	HealthCheck  HealthCheckArgs
+	Environment  pulumi.StringMap
+	Cpu          string
+	Memory       string
+	DesiredCount int
+	// Autoscaling, when set, lets target tracking own the task count between
+	// its bounds instead of the fixed DesiredCount.
+	Autoscaling  *AutoscalingArgs
+	DomainName   string
+	HostedZoneId string
+	// Logs configures the container log group, retention defaults to 30
+	// days when nil.
+	Logs *LogArgs
+	// Registry hardens the service's ECR repository, see ECRRepositoryArgs
+	// for the defaults.
+	Registry *ECRRepositoryArgs
+}
+
+// serviceRegistryArgs turns tag immutability off by default for services
+// built from a Dockerfile, as every deployment pushes the build to the same
+// tag.
+func serviceRegistryArgs(args *FargateServiceArgs) (*ECRRepositoryArgs, error) {
+	if args.Image != "" || args.ImageTag != "" {
+		return args.Registry, nil
+	}
+	var registryArgs ECRRepositoryArgs
+	if args.Registry != nil {
+		registryArgs = *args.Registry
+	}
+	if registryArgs.ImmutableTags != nil && *registryArgs.ImmutableTags {
+		return nil, fmt.Errorf("registry immutableTags needs an image or imageTag, builds from a Dockerfile push the same tag on every deployment")
+	}
+	mutable := false
+	registryArgs.ImmutableTags = &mutable
+	return &registryArgs, nil
+}
+
+// SubnetTier is the kind of subnet a service's tasks run in.
+type SubnetTier string
+
+const (
+	// PublicSubnetTier routes to the internet gateway, tasks need a public IP
+	// to reach ECR and CloudWatch Logs from there.
+	PublicSubnetTier SubnetTier = "public"
+	// PrivateSubnetTier reaches the internet through NAT or VPC endpoints, a
+	// public IP on the task's ENI would never be used.
+	PrivateSubnetTier SubnetTier = "private"
+)
+
+// AssignPublicIp returns whether tasks in subnets of the tier get a public
+// IP, and rejects a requested setting that contradicts the tier.
+func (t SubnetTier) AssignPublicIp(requested *bool) (bool, error) {
+	var assign bool
+	switch t {
+	case PublicSubnetTier:
+		assign = true
+	case PrivateSubnetTier, "":
+		assign = false
+	default:
+		return false, fmt.Errorf("subnet tier must be %q or %q, got %q", PublicSubnetTier, PrivateSubnetTier, t)
+	}
+	if requested != nil && *requested != assign {
+		if assign {
+			return false, fmt.Errorf("tasks in public subnets need a public IP to reach the internet")
+		}
+		return false, fmt.Errorf("tasks in private subnets can't use a public IP")
+	}
+	return assign, nil
+}
+
+type containerDefinition struct {
+	Name         string                 `json:"name"`
+	Image        string                 `json:"image"`
+	Essential    bool                   `json:"essential"`
+	PortMappings []containerPortMapping `json:"portMappings"`
+	Environment  []containerKeyValue    `json:"environment,omitempty"`
+	// LogConfiguration sends stdout and stderr to CloudWatch Logs.
+	LogConfiguration *containerLogConfiguration `json:"logConfiguration,omitempty"`
+}
+
+type containerLogConfiguration struct {
+	LogDriver string            `json:"logDriver"`
+	Options   map[string]string `json:"options"`
+}
+
+type containerPortMapping struct {
+	ContainerPort int    `json:"containerPort"`
+	HostPort      int    `json:"hostPort"`
+	Protocol      string `json:"protocol"`
+}
+
+type containerKeyValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+const taskExecAssumeRolePolicy = `{
+"Version": "2008-10-17",
+"Statement": [{
+	"Sid": "",
+	"Effect": "Allow",
+	"Principal": {
+		"Service": "ecs-tasks.amazonaws.com"
+	},
+	"Action": "sts:AssumeRole"
+}]
+}`
+
+// NewFargateService runs a single container behind its own load balancer:
+// the ECR repository and image, task execution role, Fargate task definition
+// and ECS service are all created from args.
+func NewFargateService(ctx *pulumi.Context, args *FargateServiceArgs, opts ...pulumi.ResourceOption) (_ *FargateService, err error) {
+	var resource FargateService
+
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("service %s: %w", args.Name, err)
+		}
+	}()
+
+	prefix := args.NetworkName + "-" + args.Name
+
+	err = ctx.RegisterComponentResource("air-tek:infra:application", prefix, &resource, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	listenerPort := args.ListenerPort
+	if listenerPort == 0 {
+		listenerPort = args.Port
+	}
+	cpu := args.Cpu
+	if cpu == "" {
+		cpu = DefaultCpu
+	}
+	memory := args.Memory
+	if memory == "" {
+		memory = DefaultMemory
+	}
+	desiredCount := args.DesiredCount
+	if desiredCount == 0 {
+		desiredCount = 1
+	}
+	serviceOpts := []pulumi.ResourceOption{pulumi.Parent(&resource)}
+	if args.Autoscaling != nil {
+		if err := args.Autoscaling.Validate(); err != nil {
+			return nil, err
+		}
+		// The autoscaler changes the desired count at runtime, only use it
+		// to size the service when it is first created.
+		desiredCount = args.Autoscaling.MinCount
+		serviceOpts = append(serviceOpts, pulumi.IgnoreChanges([]string{"desiredCount"}))
+	}
+	if args.Logs != nil {
+		if err := args.Logs.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	assignPublicIp, err := args.SubnetTier.AssignPublicIp(args.AssignPublicIp)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateFargateSize(cpu, memory); err != nil {
+		return nil, err
+	}
+
+	var url, targetGroupArn, resourceLabel pulumi.StringOutput
+	var loadBalancer pulumi.Resource
+	if args.SharedLoadBalancer != nil {
+		route, err := NewLoadBalancerRoute(ctx, &LoadBalancerRouteArgs{
+			RouteName:    prefix + "-route",
+			LoadBalancer: args.SharedLoadBalancer,
+			TargetPort:   args.Port,
+			HealthCheck:  args.HealthCheck,
+			Rule:         args.Rule,
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, err
+		}
+		url, targetGroupArn, resourceLabel, loadBalancer = route.Url, route.TargetGroupArn, route.ResourceLabel, route
+	} else {
+		lb, err := NewLoadBalancer(ctx, &LoadBalancerArgs{
+			LoadBalancerName: prefix + "-lb",
+			VpcId:            args.VpcId,
+			Subnets:          args.LoadBalancerSubnets,
+			SecurityGroups:   args.LoadBalancerSecurityGroups,
+			Internal:         args.Internal,
+			HealthCheck:      args.HealthCheck,
+			ListenerPort:     listenerPort,
+			TargetPort:       args.Port,
+			DomainName:       args.DomainName,
+			HostedZoneId:     args.HostedZoneId,
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, err
+		}
+		url, targetGroupArn, resourceLabel, loadBalancer = lb.Url, lb.TargetGroupArn, lb.ResourceLabel, lb
+	}
+	// ECS only accepts target groups that are attached to a load balancer,
+	// so the service waits for the listener or listener rule.
+	serviceOpts = append(serviceOpts, pulumi.DependsOn([]pulumi.Resource{loadBalancer}))
+
+	registryArgs, err := serviceRegistryArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := NewECRRepository(ctx, prefix+"-ecr", registryArgs, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, err
+	}
+
+	imageName, err := ResolveImage(ctx, registry, &ImageArgs{
+		ImageName:  args.Name,
+		Image:      args.Image,
+		ImageTag:   args.ImageTag,
+		Dockerfile: args.Dockerfile,
+		Context:    args.BuildContext,
+	}, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, err
+	}
+
+	region, err := aws.GetRegion(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting region: %w", err)
+	}
+
+	logGroupArgs := &cloudwatch.LogGroupArgs{
+		RetentionInDays: pulumi.Int(args.Logs.retentionInDays()),
+	}
+	if args.Logs != nil && args.Logs.KmsKeyId != "" {
+		logGroupArgs.KmsKeyId = pulumi.String(args.Logs.KmsKeyId)
+	}
+	logGroup, err := cloudwatch.NewLogGroup(ctx, prefix+"-logs", logGroupArgs, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", prefix+"-logs", err)
+	}
+
+	environment := args.Environment
+	if environment == nil {
+		environment = pulumi.StringMap{}
+	}
+
+	containerDef := pulumi.All(imageName, environment.ToStringMapOutput(), logGroup.Name).ApplyT(func(values []interface{}) (string, error) {
+		image := values[0].(string)
+		env := values[1].(map[string]string)
+		logGroupName := values[2].(string)
+
+		names := make([]string, 0, len(env))
+		for name := range env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		container := containerDefinition{
+			Name:      args.Name,
+			Image:     image,
+			Essential: true,
+			PortMappings: []containerPortMapping{{
+				ContainerPort: args.Port,
+				HostPort:      args.Port,
+				Protocol:      "tcp",
+			}},
+			LogConfiguration: &containerLogConfiguration{
+				LogDriver: "awslogs",
+				Options: map[string]string{
+					"awslogs-group":         logGroupName,
+					"awslogs-region":        region.Name,
+					"awslogs-stream-prefix": args.Name,
+				},
+			},
+		}
+		for _, name := range names {
+			container.Environment = append(container.Environment, containerKeyValue{Name: name, Value: env[name]})
+		}
+
+		definitions, err := json.Marshal([]containerDefinition{container})
+		if err != nil {
+			return "", err
+		}
+		return string(definitions), nil
+	}).(pulumi.StringOutput)
+
+	taskExecRole, err := iam.NewRole(ctx, prefix+"-task-exec-role", &iam.RoleArgs{
+		AssumeRolePolicy: pulumi.String(taskExecAssumeRolePolicy),
+	}, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", prefix+"-task-exec-role", err)
+	}
+	_, err = iam.NewRolePolicyAttachment(ctx, prefix+"-task-exec-policy", &iam.RolePolicyAttachmentArgs{
+		Role:      taskExecRole.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/service-role/AmazonECSTaskExecutionRolePolicy"),
+	}, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", prefix+"-task-exec-policy", err)
+	}
+
+	taskDefinitionArgs := &ecs.TaskDefinitionArgs{
+		Family:                  pulumi.String(args.Name + "-ecs-task-definition"),
+		Cpu:                     pulumi.String(cpu),
+		Memory:                  pulumi.String(memory),
+		NetworkMode:             pulumi.String(awsvpcNetworkMode),
+		RequiresCompatibilities: pulumi.StringArray{pulumi.String(FargateLaunchType)},
+		ExecutionRoleArn:        taskExecRole.Arn,
+		ContainerDefinitions:    containerDef,
+	}
+	serviceArgs := &ecs.ServiceArgs{
+		Cluster:      args.EcsClusterArn,
+		DesiredCount: pulumi.Int(desiredCount),
+		LaunchType:   pulumi.String(FargateLaunchType),
+		NetworkConfiguration: &ecs.ServiceNetworkConfigurationArgs{
+			AssignPublicIp: pulumi.Bool(assignPublicIp),
+			Subnets:        args.Subnets,
+			SecurityGroups: args.SecurityGroups,
+		},
+		LoadBalancers: ecs.ServiceLoadBalancerArray{
+			ecs.ServiceLoadBalancerArgs{
+				TargetGroupArn: targetGroupArn,
+				ContainerName:  pulumi.String(args.Name),
+				ContainerPort:  pulumi.Int(args.Port),
+			},
+		},
+	}
+	if err := validateLaunch(taskDefinitionArgs, serviceArgs); err != nil {
+		return nil, err
+	}
+
+	taskDefinition, err := ecs.NewTaskDefinition(ctx, prefix+"-ecs-task-def", taskDefinitionArgs, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", prefix+"-ecs-task-def", err)
+	}
+
+	serviceArgs.TaskDefinition = taskDefinition.Arn
+	service, err := ecs.NewService(ctx, prefix+"-ecs-service", serviceArgs, serviceOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", prefix+"-ecs-service", err)
+	}
+
+	if args.Autoscaling != nil {
+		err = newServiceAutoscaling(ctx, prefix, args.Autoscaling, args.EcsClusterArn, service.Name, resourceLabel, &resource)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resource.Url = url
+	resource.LogGroupName = logGroup.Name
+
+	ctx.RegisterResourceOutputs(&resource, pulumi.Map{
+		"Url":          url,
+		"LogGroupName": logGroup.Name,
+	})
+
+	return &resource, nil
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"air-tek-iac/testutil"
+	"encoding/json"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestFargateServiceAutoscaling(t *testing.T) {
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewFargateService(ctx, &FargateServiceArgs{
+			Name:          "svc",
+			NetworkName:   "test",
+			VpcId:         pulumi.String("vpc-1"),
+			EcsClusterArn: pulumi.String("arn:aws:ecs:us-east-1:123456789012:cluster/test-cluster"),
+			Image:         "registry.example.com/svc:1",
+			Port:          8080,
+			Autoscaling: &AutoscalingArgs{
+				MinCount:           2,
+				MaxCount:           6,
+				CpuTarget:          60,
+				RequestCountTarget: 100,
+			},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := mocks.Resource(t, "aws:appautoscaling/target:Target", "test-svc-scaling-target")
+	if target.StringValue("resourceId") != "service/test-cluster/test-svc-ecs-service" {
+		t.Errorf("scaling target resource id = %q", target.StringValue("resourceId"))
+	}
+
+	policies := mocks.Resources("aws:appautoscaling/policy:Policy")
+	if len(policies) != 2 {
+		t.Fatalf("got %d scaling policies, want 2", len(policies))
+	}
+	requestCount := mocks.Resource(t, "aws:appautoscaling/policy:Policy", "test-svc-request-count-scaling-policy")
+	metric := requestCount.Inputs["targetTrackingScalingPolicyConfiguration"].ObjectValue()["predefinedMetricSpecification"].ObjectValue()
+	if label := metric["resourceLabel"].StringValue(); label != "app/test-svc-lb/1234/targetgroup/test-svc-lb-tg/5678" {
+		t.Errorf("request count resource label = %q", label)
+	}
+
+	service := mocks.Resource(t, "aws:ecs/service:Service", "test-svc-ecs-service")
+	if count := service.Inputs["desiredCount"].NumberValue(); count != 2 {
+		t.Errorf("desired count = %v, want the minimum of 2", count)
+	}
+}
+
+func TestFargateServiceLaunchMatchesTaskDefinition(t *testing.T) {
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewFargateService(ctx, testServiceArgs())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	taskDefinition := mocks.Resource(t, "aws:ecs/taskDefinition:TaskDefinition", "test-svc-ecs-task-def")
+	service := mocks.Resource(t, "aws:ecs/service:Service", "test-svc-ecs-service")
+	launchType := service.StringValue("launchType")
+	if launchType != "FARGATE" {
+		t.Errorf("service launch type = %q, want FARGATE", launchType)
+	}
+	if compatibilities := taskDefinition.StringArray("requiresCompatibilities"); len(compatibilities) != 1 || compatibilities[0] != launchType {
+		t.Errorf("task definition compatibilities = %v, want the service's launch type %s", compatibilities, launchType)
+	}
+	if mode := taskDefinition.StringValue("networkMode"); mode != "awsvpc" {
+		t.Errorf("task definition network mode = %q, want awsvpc for Fargate", mode)
+	}
+}
+
+func TestFargateServiceRejectsInvalidAutoscaling(t *testing.T) {
+	_, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewFargateService(ctx, &FargateServiceArgs{
+			Name:          "svc",
+			NetworkName:   "test",
+			EcsClusterArn: pulumi.String("arn:aws:ecs:us-east-1:123456789012:cluster/test-cluster"),
+			Image:         "registry.example.com/svc:1",
+			Port:          8080,
+			Autoscaling:   &AutoscalingArgs{MinCount: 3, MaxCount: 2, CpuTarget: 50},
+		})
+		return err
+	})
+	if err == nil {
+		t.Error("expected an error for maxCount below minCount")
+	}
+}
+
+func TestFargateServiceLogs(t *testing.T) {
+	retention := 90
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewFargateService(ctx, &FargateServiceArgs{
+			Name:          "svc",
+			NetworkName:   "test",
+			EcsClusterArn: pulumi.String("arn:aws:ecs:us-east-1:123456789012:cluster/test-cluster"),
+			Image:         "registry.example.com/svc:1",
+			Port:          8080,
+			Logs: &LogArgs{
+				RetentionInDays: &retention,
+				KmsKeyId:        "arn:aws:kms:us-east-1:123456789012:key/logs",
+			},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logGroup := mocks.Resource(t, "aws:cloudwatch/logGroup:LogGroup", "test-svc-logs")
+	if days := logGroup.Inputs["retentionInDays"].NumberValue(); days != 90 {
+		t.Errorf("retention = %v, want 90", days)
+	}
+	if key := logGroup.StringValue("kmsKeyId"); key != "arn:aws:kms:us-east-1:123456789012:key/logs" {
+		t.Errorf("kms key = %q", key)
+	}
+
+	taskDefinition := mocks.Resource(t, "aws:ecs/taskDefinition:TaskDefinition", "test-svc-ecs-task-def")
+	var containers []containerDefinition
+	if err := json.Unmarshal([]byte(taskDefinition.StringValue("containerDefinitions")), &containers); err != nil {
+		t.Fatal(err)
+	}
+	logConfiguration := containers[0].LogConfiguration
+	if logConfiguration == nil || logConfiguration.LogDriver != "awslogs" {
+		t.Fatalf("log configuration = %+v, want the awslogs driver", logConfiguration)
+	}
+	want := map[string]string{
+		"awslogs-group":         "test-svc-logs",
+		"awslogs-region":        "us-east-1",
+		"awslogs-stream-prefix": "svc",
+	}
+	for key, value := range want {
+		if logConfiguration.Options[key] != value {
+			t.Errorf("%s = %q, want %q", key, logConfiguration.Options[key], value)
+		}
+	}
+}
+
+func TestFargateServiceRejectsInvalidLogRetention(t *testing.T) {
+	retention := 10
+	_, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewFargateService(ctx, &FargateServiceArgs{
+			Name:          "svc",
+			NetworkName:   "test",
+			EcsClusterArn: pulumi.String("arn:aws:ecs:us-east-1:123456789012:cluster/test-cluster"),
+			Image:         "registry.example.com/svc:1",
+			Port:          8080,
+			Logs:          &LogArgs{RetentionInDays: &retention},
+		})
+		return err
+	})
+	if err == nil {
+		t.Error("expected an error for a retention CloudWatch Logs doesn't support")
+	}
+}
+
+func TestSubnetTierAssignPublicIp(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		tier      SubnetTier
+		requested *bool
+		want      bool
+		wantErr   bool
+	}{
+		{tier: "", want: false},
+		{tier: PrivateSubnetTier, want: false},
+		{tier: PrivateSubnetTier, requested: &no, want: false},
+		{tier: PrivateSubnetTier, requested: &yes, wantErr: true},
+		{tier: PublicSubnetTier, want: true},
+		{tier: PublicSubnetTier, requested: &yes, want: true},
+		{tier: PublicSubnetTier, requested: &no, wantErr: true},
+		{tier: "isolated", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := test.tier.AssignPublicIp(test.requested)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", test.tier, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: assign = %v, want %v", test.tier, got, test.want)
+		}
+	}
+}
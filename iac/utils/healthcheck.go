@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	elb "github.com/pulumi/pulumi-aws/sdk/v5/go/aws/elasticloadbalancingv2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// HealthCheckArgs tunes how the load balancer decides a target is healthy.
+// Fields left empty get the load balancer defaults.
+type HealthCheckArgs struct {
+	// Path defaults to /.
+	Path string `json:"path"`
+	// Port is a port number or traffic-port, the default, for the port the
+	// target receives traffic on.
+	Port string `json:"port"`
+	// Protocol is HTTP, the default, or HTTPS.
+	Protocol string `json:"protocol"`
+	// Matcher lists the healthy response codes between 200 and 499, e.g.
+	// 200,202 or 200-299. Defaults to 200.
+	Matcher string `json:"matcher"`
+	// Interval between checks in seconds, 5 to 300, default 30.
+	Interval int `json:"interval"`
+	// Timeout of a check in seconds, 2 to 120 and below Interval, default 5.
+	Timeout int `json:"timeout"`
+	// HealthyThreshold and UnhealthyThreshold are the number of consecutive
+	// checks that change a target's state, 2 to 10, default 5 and 2.
+	HealthyThreshold   int `json:"healthyThreshold"`
+	UnhealthyThreshold int `json:"unhealthyThreshold"`
+	// DeregistrationDelay is how long in seconds draining targets keep
+	// serving in-flight requests, 0 to 3600, default 300.
+	DeregistrationDelay *int `json:"deregistrationDelay"`
+}
+
+// withDefaults returns a copy of the args with every unset field defaulted.
+func (a HealthCheckArgs) withDefaults() HealthCheckArgs {
+	if a.Path == "" {
+		a.Path = "/"
+	}
+	if a.Port == "" {
+		a.Port = "traffic-port"
+	}
+	if a.Protocol == "" {
+		a.Protocol = "HTTP"
+	}
+	if a.Matcher == "" {
+		a.Matcher = "200"
+	}
+	if a.Interval == 0 {
+		a.Interval = 30
+	}
+	if a.Timeout == 0 {
+		a.Timeout = 5
+	}
+	if a.HealthyThreshold == 0 {
+		a.HealthyThreshold = 5
+	}
+	if a.UnhealthyThreshold == 0 {
+		a.UnhealthyThreshold = 2
+	}
+	if a.DeregistrationDelay == nil {
+		delay := 300
+		a.DeregistrationDelay = &delay
+	}
+	return a
+}
+
+func (a HealthCheckArgs) Validate() error {
+	a = a.withDefaults()
+	if a.Path[0] != '/' {
+		return fmt.Errorf("health check path %q must start with /", a.Path)
+	}
+	if a.Port != "traffic-port" {
+		if port, err := strconv.Atoi(a.Port); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("health check port must be traffic-port or between 1 and 65535, got %q", a.Port)
+		}
+	}
+	if a.Protocol != "HTTP" && a.Protocol != "HTTPS" {
+		return fmt.Errorf("health check protocol must be HTTP or HTTPS, got %q", a.Protocol)
+	}
+	if !validMatcher(a.Matcher) {
+		return fmt.Errorf("health check matcher must be a code, a comma separated list or a range of codes between 200 and 499, got %q", a.Matcher)
+	}
+	if a.Interval < 5 || a.Interval > 300 {
+		return fmt.Errorf("health check interval must be between 5 and 300 seconds, got %d", a.Interval)
+	}
+	if a.Timeout < 2 || a.Timeout > 120 || a.Timeout >= a.Interval {
+		return fmt.Errorf("health check timeout must be between 2 and 120 seconds and below the interval of %d, got %d", a.Interval, a.Timeout)
+	}
+	if a.HealthyThreshold < 2 || a.HealthyThreshold > 10 || a.UnhealthyThreshold < 2 || a.UnhealthyThreshold > 10 {
+		return fmt.Errorf("health check thresholds must be between 2 and 10, got %d healthy and %d unhealthy", a.HealthyThreshold, a.UnhealthyThreshold)
+	}
+	if *a.DeregistrationDelay < 0 || *a.DeregistrationDelay > 3600 {
+		return fmt.Errorf("deregistration delay must be between 0 and 3600 seconds, got %d", *a.DeregistrationDelay)
+	}
+	return nil
+}
+
+// validMatcher reports whether matcher is an HTTP code the load balancer
+// accepts as healthy, a list like 200,202 or a range like 200-299.
+func validMatcher(matcher string) bool {
+	if low, high, isRange := strings.Cut(matcher, "-"); isRange {
+		return validMatcherCode(low) && validMatcherCode(high) && low <= high
+	}
+	for _, code := range strings.Split(matcher, ",") {
+		if !validMatcherCode(code) {
+			return false
+		}
+	}
+	return true
+}
+
+func validMatcherCode(code string) bool {
+	value, err := strconv.Atoi(code)
+	return err == nil && len(code) == 3 && value >= 200 && value <= 499
+}
+
+func (a HealthCheckArgs) targetGroupHealthCheck() *elb.TargetGroupHealthCheckArgs {
+	a = a.withDefaults()
+	return &elb.TargetGroupHealthCheckArgs{
+		Path:               pulumi.String(a.Path),
+		Port:               pulumi.String(a.Port),
+		Protocol:           pulumi.String(a.Protocol),
+		Matcher:            pulumi.String(a.Matcher),
+		Interval:           pulumi.Int(a.Interval),
+		Timeout:            pulumi.Int(a.Timeout),
+		HealthyThreshold:   pulumi.Int(a.HealthyThreshold),
+		UnhealthyThreshold: pulumi.Int(a.UnhealthyThreshold),
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"air-tek-iac/testutil"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const targetGroupType = "aws:elasticloadbalancingv2/targetGroup:TargetGroup"
+
+func TestLoadBalancerHealthCheck(t *testing.T) {
+	delay := 30
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewLoadBalancer(ctx, &LoadBalancerArgs{
+			LoadBalancerName: "test-lb",
+			VpcId:            pulumi.String("vpc-1"),
+			ListenerPort:     80,
+			TargetPort:       5000,
+			HealthCheck: HealthCheckArgs{
+				Path:                "/health",
+				Port:                "8080",
+				Matcher:             "200-299",
+				Interval:            10,
+				Timeout:             3,
+				HealthyThreshold:    2,
+				UnhealthyThreshold:  3,
+				DeregistrationDelay: &delay,
+			},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targetGroup := mocks.Resource(t, targetGroupType, "test-lb-tg")
+	if value := targetGroup.Inputs["deregistrationDelay"]; !value.IsNumber() || value.NumberValue() != 30 {
+		t.Errorf("deregistrationDelay = %v, want 30", value)
+	}
+	healthCheck := targetGroup.Inputs["healthCheck"].ObjectValue()
+	for key, want := range map[string]string{"path": "/health", "port": "8080", "protocol": "HTTP", "matcher": "200-299"} {
+		if got := healthCheck[resource.PropertyKey(key)].StringValue(); got != want {
+			t.Errorf("health check %s = %q, want %q", key, got, want)
+		}
+	}
+	for key, want := range map[string]float64{"interval": 10, "timeout": 3, "healthyThreshold": 2, "unhealthyThreshold": 3} {
+		if got := healthCheck[resource.PropertyKey(key)].NumberValue(); got != want {
+			t.Errorf("health check %s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestHealthCheckArgsValidate(t *testing.T) {
+	negative := -1
+	tests := map[string]struct {
+		args  HealthCheckArgs
+		valid bool
+	}{
+		"defaults":              {HealthCheckArgs{}, true},
+		"port":                  {HealthCheckArgs{Port: "8080", Protocol: "HTTPS"}, true},
+		"relative path":         {HealthCheckArgs{Path: "health"}, false},
+		"bad port":              {HealthCheckArgs{Port: "70000"}, false},
+		"bad protocol":          {HealthCheckArgs{Protocol: "TCP"}, false},
+		"matcher list":          {HealthCheckArgs{Matcher: "200,202"}, true},
+		"matcher range":         {HealthCheckArgs{Matcher: "200-299"}, true},
+		"matcher not a code":    {HealthCheckArgs{Matcher: "abc"}, false},
+		"matcher out of range":  {HealthCheckArgs{Matcher: "700"}, false},
+		"matcher bad list":      {HealthCheckArgs{Matcher: "200,"}, false},
+		"matcher reversed":      {HealthCheckArgs{Matcher: "299-200"}, false},
+		"matcher range too far": {HealthCheckArgs{Matcher: "200-599"}, false},
+		"short interval":        {HealthCheckArgs{Interval: 4}, false},
+		"timeout over interval": {HealthCheckArgs{Interval: 10, Timeout: 10}, false},
+		"bad threshold":         {HealthCheckArgs{UnhealthyThreshold: 11}, false},
+		"negative delay":        {HealthCheckArgs{DeregistrationDelay: &negative}, false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := test.args.Validate(); (err == nil) != test.valid {
+				t.Errorf("err = %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pulumi/pulumi-docker/sdk/v4/go/docker"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type ImageArgs struct {
+	ImageName string
+	// Image is a full image reference, e.g. a repository URI with a tag or
+	// digest built by CI. It is deployed as is.
+	Image string
+	// ImageTag is a tag already pushed to the service's own repository.
+	ImageTag string
+	// Dockerfile is built and pushed to the repository when neither Image
+	// nor ImageTag is set, from Context which defaults to the repository root.
+	Dockerfile string
+	Context    string
+}
+
+// ResolveImage returns the image reference a service should run. Pre-built
+// images are used as given, only when none is configured is the Dockerfile
+// built and pushed to the repository as part of the deployment.
+func ResolveImage(ctx *pulumi.Context, registry *ECRRepository, args *ImageArgs, opts ...pulumi.ResourceOption) (pulumi.StringOutput, error) {
+	if args.Image != "" && args.ImageTag != "" {
+		return pulumi.StringOutput{}, errors.New("only one of image and imageTag can be set")
+	}
+
+	if args.Image != "" {
+		return pulumi.String(args.Image).ToStringOutput(), nil
+	}
+
+	if args.ImageTag != "" {
+		return registry.RepositoryUrl.ApplyT(func(url string) string {
+			return url + ":" + args.ImageTag
+		}).(pulumi.StringOutput), nil
+	}
+
+	buildContext := args.Context
+	if buildContext == "" {
+		buildContext = ".."
+	}
+
+	image, err := docker.NewImage(ctx, args.ImageName, &docker.ImageArgs{
+		Build: docker.DockerBuildArgs{
+			Context:    pulumi.String(buildContext),
+			Dockerfile: pulumi.String(args.Dockerfile),
+			Platform:   pulumi.String("linux/amd64"),
+		},
+		ImageName: registry.RepositoryUrl,
+		Registry: docker.RegistryArgs{
+			Server:   registry.RepositoryUrl,
+			Username: registry.User,
+			Password: registry.Pass,
+		},
+	}, opts...)
+	if err != nil {
+		return pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", args.ImageName, err)
+	}
+
+	return image.ImageName, nil
+}
@@ -1,18 +1,28 @@
 package utils
 
 import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/acm"
 	elb "github.com/pulumi/pulumi-aws/sdk/v5/go/aws/elasticloadbalancingv2"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/route53"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
-	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
 )
 
 type LoadBalancer struct {
 	pulumi.ResourceState
 
 	Url            pulumi.StringOutput `pulumi:"Url"`
+	DnsName        pulumi.StringOutput `pulumi:"DnsName"`
 	TargetGroupArn pulumi.StringOutput `pulumi:"TargetGroupArn"`
+	// ResourceLabel identifies the load balancer and target group pair in
+	// CloudWatch request count metrics.
+	ResourceLabel pulumi.StringOutput `pulumi:"ResourceLabel"`
 }
 
+// Policy supporting TLS 1.3 with TLS 1.2 as the minimum protocol version.
+const defaultSslPolicy = "ELBSecurityPolicy-TLS13-1-2-2021-06"
+
 type LoadBalancerArgs struct {
 	LoadBalancerName string
 	VpcId            pulumi.StringInput
@@ -20,17 +30,33 @@ type LoadBalancerArgs struct {
 	SecurityGroups   pulumi.StringArrayInput
 	ListenerPort     int
 	TargetPort       int
-	HealthCheckPath  string
-	HealthCheckPort  string
+	HealthCheck      HealthCheckArgs
 	Internal         bool
+	// DomainName, when set, gets a DNS-validated ACM certificate and an alias
+	// record in HostedZoneId. Traffic is then served over HTTPS on 443 and
+	// ListenerPort redirects to it.
+	DomainName   string
+	HostedZoneId string
+	SslPolicy    string
 }
 
-func NewLoadBalancer(ctx *pulumi.Context, args *LoadBalancerArgs, opts ...pulumi.ResourceOption) (*LoadBalancer, error) {
+func NewLoadBalancer(ctx *pulumi.Context, args *LoadBalancerArgs, opts ...pulumi.ResourceOption) (_ *LoadBalancer, err error) {
 	var resource LoadBalancer
 
-	config := config.New(ctx, "network")
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("load balancer %s: %w", args.LoadBalancerName, err)
+		}
+	}()
 
-	err := ctx.RegisterComponentResource("air-tek:infra:loadbalancer", args.LoadBalancerName, &resource, opts...)
+	if args.DomainName != "" && args.HostedZoneId == "" {
+		return nil, fmt.Errorf("a hosted zone id is required with domain name %s", args.DomainName)
+	}
+	if err := args.HealthCheck.Validate(); err != nil {
+		return nil, err
+	}
+
+	err = ctx.RegisterComponentResource("air-tek:infra:loadbalancer", args.LoadBalancerName, &resource, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -40,54 +66,182 @@ func NewLoadBalancer(ctx *pulumi.Context, args *LoadBalancerArgs, opts ...pulumi
 		Subnets:        args.Subnets,
 		SecurityGroups: args.SecurityGroups,
 		Internal:       pulumi.Bool(args.Internal),
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
-		},
 	}, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", args.LoadBalancerName, err)
+	}
+	targetGroup, err := newTargetGroup(ctx, args.LoadBalancerName+"-tg", args.VpcId, args.TargetPort, args.HealthCheck, &resource)
 	if err != nil {
 		return nil, err
 	}
-	targetGroup, err := elb.NewTargetGroup(ctx, args.LoadBalancerName+"-tg", &elb.TargetGroupArgs{
-		Port:       pulumi.Int(args.TargetPort),
-		Protocol:   pulumi.String("HTTP"),
-		TargetType: pulumi.String("ip"),
-		VpcId:      args.VpcId,
-		HealthCheck: &elb.TargetGroupHealthCheckArgs{
-			Path: pulumi.String(args.HealthCheckPath),
-		},
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
+
+	_, url, err := newListeners(ctx, listenerArgs{
+		Name:         args.LoadBalancerName,
+		ListenerPort: args.ListenerPort,
+		DomainName:   args.DomainName,
+		HostedZoneId: args.HostedZoneId,
+		SslPolicy:    args.SslPolicy,
+		DefaultAction: elb.ListenerDefaultActionArgs{
+			Type:           pulumi.String("forward"),
+			TargetGroupArn: targetGroup.Arn,
 		},
-	}, pulumi.Parent(&resource))
+	}, alb, &resource)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = elb.NewListener(ctx, args.LoadBalancerName+"-listener", &elb.ListenerArgs{
+	resource.Url = url
+	resource.DnsName = alb.DnsName
+	resource.TargetGroupArn = targetGroup.Arn
+	resource.ResourceLabel = pulumi.Sprintf("%s/%s", alb.ArnSuffix, targetGroup.ArnSuffix)
+
+	ctx.RegisterResourceOutputs(&resource, pulumi.Map{
+		"Url":            url,
+		"DnsName":        alb.DnsName,
+		"TargetGroupArn": targetGroup.Arn,
+		"ResourceLabel":  resource.ResourceLabel,
+	})
+
+	return &resource, nil
+}
+
+// listenerArgs are the listener settings of both the per-service and the
+// shared load balancer.
+type listenerArgs struct {
+	Name         string
+	ListenerPort int
+	DomainName   string
+	HostedZoneId string
+	SslPolicy    string
+	// DefaultAction handles the requests no listener rule matches.
+	DefaultAction elb.ListenerDefaultActionArgs
+}
+
+// newListeners serves ListenerPort over HTTP, or over HTTPS when a domain
+// name is set. It returns the listener that carries DefaultAction and the url
+// of the load balancer.
+func newListeners(ctx *pulumi.Context, args listenerArgs, alb *elb.LoadBalancer, parent pulumi.Resource) (*elb.Listener, pulumi.StringOutput, error) {
+	if args.DomainName != "" {
+		return newHttpsListeners(ctx, args, alb, parent)
+	}
+
+	listener, err := elb.NewListener(ctx, args.Name+"-listener", &elb.ListenerArgs{
+		LoadBalancerArn: alb.Arn,
+		Port:            pulumi.Int(args.ListenerPort),
+		DefaultActions:  elb.ListenerDefaultActionArray{args.DefaultAction},
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return nil, pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", args.Name+"-listener", err)
+	}
+
+	url := alb.DnsName.ApplyT(func(dnsName string) string {
+		if args.ListenerPort == 80 {
+			return "http://" + dnsName
+		}
+		return fmt.Sprintf("http://%s:%d", dnsName, args.ListenerPort)
+	}).(pulumi.StringOutput)
+	return listener, url, nil
+}
+
+// newHttpsListeners issues a DNS-validated certificate for args.DomainName,
+// serves the default action over HTTPS, redirects the plain listener to it
+// and points the domain at the load balancer.
+func newHttpsListeners(ctx *pulumi.Context, args listenerArgs, alb *elb.LoadBalancer, parent pulumi.Resource) (*elb.Listener, pulumi.StringOutput, error) {
+	certificate, err := acm.NewCertificate(ctx, args.Name+"-cert", &acm.CertificateArgs{
+		DomainName:       pulumi.String(args.DomainName),
+		ValidationMethod: pulumi.String("DNS"),
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return nil, pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", args.Name+"-cert", err)
+	}
+
+	validationOption := certificate.DomainValidationOptions.Index(pulumi.Int(0))
+	validationRecord, err := route53.NewRecord(ctx, args.Name+"-cert-validation-record", &route53.RecordArgs{
+		ZoneId:         pulumi.String(args.HostedZoneId),
+		Name:           validationOption.ResourceRecordName().Elem(),
+		Type:           validationOption.ResourceRecordType().Elem(),
+		Records:        pulumi.StringArray{validationOption.ResourceRecordValue().Elem()},
+		Ttl:            pulumi.Int(60),
+		AllowOverwrite: pulumi.Bool(true),
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return nil, pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", args.Name+"-cert-validation-record", err)
+	}
+
+	certificateValidation, err := acm.NewCertificateValidation(ctx, args.Name+"-cert-validation", &acm.CertificateValidationArgs{
+		CertificateArn:        certificate.Arn,
+		ValidationRecordFqdns: pulumi.StringArray{validationRecord.Fqdn},
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return nil, pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", args.Name+"-cert-validation", err)
+	}
+
+	sslPolicy := args.SslPolicy
+	if sslPolicy == "" {
+		sslPolicy = defaultSslPolicy
+	}
+
+	listener, err := elb.NewListener(ctx, args.Name+"-https-listener", &elb.ListenerArgs{
+		LoadBalancerArn: alb.Arn,
+		Port:            pulumi.Int(443),
+		Protocol:        pulumi.String("HTTPS"),
+		SslPolicy:       pulumi.String(sslPolicy),
+		CertificateArn:  certificateValidation.CertificateArn,
+		DefaultActions:  elb.ListenerDefaultActionArray{args.DefaultAction},
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return nil, pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", args.Name+"-https-listener", err)
+	}
+
+	_, err = elb.NewListener(ctx, args.Name+"-listener", &elb.ListenerArgs{
 		LoadBalancerArn: alb.Arn,
 		Port:            pulumi.Int(args.ListenerPort),
 		DefaultActions: elb.ListenerDefaultActionArray{
 			elb.ListenerDefaultActionArgs{
-				Type:           pulumi.String("forward"),
-				TargetGroupArn: targetGroup.Arn,
+				Type: pulumi.String("redirect"),
+				Redirect: &elb.ListenerDefaultActionRedirectArgs{
+					Port:       pulumi.String("443"),
+					Protocol:   pulumi.String("HTTPS"),
+					StatusCode: pulumi.String("HTTP_301"),
+				},
 			},
 		},
-		Tags: &pulumi.StringMap{
-			"air-tek:project": pulumi.String(ctx.Project()),
-			"air-tek:stack":   pulumi.String(ctx.Stack()),
-			"air-tek:network": pulumi.String(config.Require("name")),
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return nil, pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", args.Name+"-listener", err)
+	}
+
+	_, err = route53.NewRecord(ctx, args.Name+"-dns-record", &route53.RecordArgs{
+		ZoneId: pulumi.String(args.HostedZoneId),
+		Name:   pulumi.String(args.DomainName),
+		Type:   pulumi.String("A"),
+		Aliases: route53.RecordAliasArray{
+			&route53.RecordAliasArgs{
+				Name:                 alb.DnsName,
+				ZoneId:               alb.ZoneId,
+				EvaluateTargetHealth: pulumi.Bool(true),
+			},
 		},
-	}, pulumi.Parent(&resource))
+	}, pulumi.Parent(parent))
 	if err != nil {
-		return nil, err
+		return nil, pulumi.StringOutput{}, fmt.Errorf("creating %s: %w", args.Name+"-dns-record", err)
 	}
 
-	resource.Url = alb.DnsName
-	resource.TargetGroupArn = targetGroup.Arn
+	return listener, pulumi.String("https://" + args.DomainName).ToStringOutput(), nil
+}
 
-	return &resource, nil
+// newTargetGroup registers tasks by ip on port and checks their health.
+func newTargetGroup(ctx *pulumi.Context, name string, vpcId pulumi.StringInput, port int, healthCheck HealthCheckArgs, parent pulumi.Resource) (*elb.TargetGroup, error) {
+	targetGroup, err := elb.NewTargetGroup(ctx, name, &elb.TargetGroupArgs{
+		Port:                pulumi.Int(port),
+		Protocol:            pulumi.String("HTTP"),
+		TargetType:          pulumi.String("ip"),
+		VpcId:               vpcId,
+		HealthCheck:         healthCheck.targetGroupHealthCheck(),
+		DeregistrationDelay: pulumi.Int(*healthCheck.withDefaults().DeregistrationDelay),
+	}, pulumi.Parent(parent))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", name, err)
+	}
+	return targetGroup, nil
 }
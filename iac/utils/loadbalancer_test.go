@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"air-tek-iac/testutil"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	loadBalancerType = "aws:elasticloadbalancingv2/loadBalancer:LoadBalancer"
+	listenerType     = "aws:elasticloadbalancingv2/listener:Listener"
+)
+
+func TestLoadBalancerInternal(t *testing.T) {
+	for _, internal := range []bool{true, false} {
+		mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+			_, err := NewLoadBalancer(ctx, &LoadBalancerArgs{
+				LoadBalancerName: "test-lb",
+				VpcId:            pulumi.String("vpc-1"),
+				Subnets:          pulumi.StringArray{pulumi.String("subnet-a"), pulumi.String("subnet-b")},
+				SecurityGroups:   pulumi.StringArray{pulumi.String("sg-1")},
+				ListenerPort:     5000,
+				TargetPort:       5000,
+				HealthCheck:      HealthCheckArgs{Path: "/health"},
+				Internal:         internal,
+			})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		alb := mocks.Resource(t, loadBalancerType, "test-lb")
+		if alb.BoolValue("internal") != internal {
+			t.Errorf("internal = %v, want %v", alb.BoolValue("internal"), internal)
+		}
+		if subnets := alb.StringArray("subnets"); len(subnets) != 2 || subnets[0] != "subnet-a" {
+			t.Errorf("subnets = %v", subnets)
+		}
+
+		listeners := mocks.Resources(listenerType)
+		if len(listeners) != 1 {
+			t.Fatalf("got %d listeners, want 1", len(listeners))
+		}
+		if action := listeners[0].Objects("defaultActions")[0]; action["type"].StringValue() != "forward" {
+			t.Errorf("default action = %v, want forward", action["type"])
+		}
+	}
+}
+
+func TestLoadBalancerUrl(t *testing.T) {
+	var url string
+	_, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		lb, err := NewLoadBalancer(ctx, &LoadBalancerArgs{
+			LoadBalancerName: "test-lb",
+			VpcId:            pulumi.String("vpc-1"),
+			ListenerPort:     5000,
+			TargetPort:       5000,
+		})
+		if err != nil {
+			return err
+		}
+		lb.Url.ApplyT(func(value string) string {
+			url = value
+			return value
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "http://test-lb.elb.amazonaws.com:5000" {
+		t.Errorf("url = %q", url)
+	}
+}
+
+func TestLoadBalancerHttps(t *testing.T) {
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewLoadBalancer(ctx, &LoadBalancerArgs{
+			LoadBalancerName: "test-lb",
+			VpcId:            pulumi.String("vpc-1"),
+			ListenerPort:     80,
+			TargetPort:       5000,
+			DomainName:       "app.example.com",
+			HostedZoneId:     "Z123",
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	https := mocks.Resource(t, listenerType, "test-lb-https-listener")
+	if https.StringValue("protocol") != "HTTPS" || https.StringValue("sslPolicy") != defaultSslPolicy {
+		t.Errorf("https listener protocol %q, ssl policy %q", https.StringValue("protocol"), https.StringValue("sslPolicy"))
+	}
+	if https.StringValue("certificateArn") == "" {
+		t.Error("https listener has no certificate")
+	}
+
+	redirect := mocks.Resource(t, listenerType, "test-lb-listener").Objects("defaultActions")[0]
+	if redirect["type"].StringValue() != "redirect" {
+		t.Errorf("http listener action = %v, want redirect", redirect["type"])
+	}
+
+	record := mocks.Resource(t, "aws:route53/record:Record", "test-lb-dns-record")
+	if record.StringValue("name") != "app.example.com" || record.StringValue("zoneId") != "Z123" {
+		t.Errorf("alias record %s in zone %s", record.StringValue("name"), record.StringValue("zoneId"))
+	}
+}
+
+func TestLoadBalancerHttpsRequiresHostedZone(t *testing.T) {
+	_, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		_, err := NewLoadBalancer(ctx, &LoadBalancerArgs{
+			LoadBalancerName: "test-lb",
+			VpcId:            pulumi.String("vpc-1"),
+			ListenerPort:     80,
+			TargetPort:       5000,
+			DomainName:       "app.example.com",
+		})
+		return err
+	})
+	if err == nil {
+		t.Error("expected an error without a hosted zone")
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import "fmt"
+
+// logRetentionDays are the retention periods CloudWatch Logs accepts. Zero
+// keeps logs forever.
+var logRetentionDays = []int{0, 1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
+// ValidateLogRetention checks days is a retention CloudWatch Logs accepts.
+func ValidateLogRetention(days int) error {
+	for _, allowed := range logRetentionDays {
+		if days == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("log retention of %d days is not supported by CloudWatch Logs, use one of %v", days, logRetentionDays)
+}
+
+const defaultLogRetentionDays = 30
+
+// LogArgs configures the CloudWatch log group a service's containers write
+// to through the awslogs driver.
+type LogArgs struct {
+	// RetentionInDays defaults to 30, zero keeps logs forever.
+	RetentionInDays *int `json:"retentionInDays"`
+	// KmsKeyId is the ARN of a KMS key to encrypt the log group with. Its key
+	// policy has to allow the CloudWatch Logs service to use it.
+	KmsKeyId string `json:"kmsKeyId"`
+}
+
+func (a *LogArgs) Validate() error {
+	if a.RetentionInDays == nil {
+		return nil
+	}
+	return ValidateLogRetention(*a.RetentionInDays)
+}
+
+func (a *LogArgs) retentionInDays() int {
+	if a == nil || a.RetentionInDays == nil {
+		return defaultLogRetentionDays
+	}
+	return *a.RetentionInDays
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ecr"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// ECRRegistry holds the settings ECR only has once per account and region:
+// replication to other regions and accounts, pull-through cache rules and the
+// registry policy.
+type ECRRegistry struct {
+	pulumi.ResourceState
+}
+
+// ECRRegistryArgs is read from the ecr config namespace by
+// LoadECRRegistryArgs.
+type ECRRegistryArgs struct {
+	// Replication copies pushed images to other regions and accounts, ECR
+	// allows up to 10 rules.
+	Replication []ReplicationRule `json:"replication"`
+	// PullThroughCache caches images of upstream registries in this one.
+	PullThroughCache []PullThroughCacheRule `json:"pullThroughCache"`
+	// AllowReplicationFrom lists the accounts that may replicate into this
+	// registry, set it on the stacks of the destination accounts.
+	AllowReplicationFrom []string `json:"allowReplicationFrom"`
+}
+
+type ReplicationRule struct {
+	// Destinations are up to 25 registries, the account may be this one to
+	// replicate to another region.
+	Destinations []ReplicationDestination `json:"destinations"`
+	// RepositoryPrefixes limits the rule to repositories whose names start
+	// with one of them, every repository is replicated when empty.
+	RepositoryPrefixes []string `json:"repositoryPrefixes"`
+}
+
+type ReplicationDestination struct {
+	Region    string `json:"region"`
+	AccountId string `json:"accountId"`
+}
+
+type PullThroughCacheRule struct {
+	// Prefix is the repository name prefix images are cached under, e.g.
+	// pulling <registry>/ecr-public/docker/library/alpine with the prefix
+	// ecr-public.
+	Prefix string `json:"prefix"`
+	// UpstreamUrl is the upstream registry, e.g. public.ecr.aws or quay.io.
+	UpstreamUrl string `json:"upstreamUrl"`
+}
+
+var accountIdPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+func validateAccountIds(key string, accountIds []string) error {
+	for _, accountId := range accountIds {
+		if !accountIdPattern.MatchString(accountId) {
+			return fmt.Errorf("%s: %q is not a 12 digit account id", key, accountId)
+		}
+	}
+	return nil
+}
+
+func (a *ECRRegistryArgs) Validate() error {
+	if len(a.Replication) > 10 {
+		return fmt.Errorf("ecr:replication: ECR allows at most 10 rules, got %d", len(a.Replication))
+	}
+	for i, rule := range a.Replication {
+		if len(rule.Destinations) == 0 || len(rule.Destinations) > 25 {
+			return fmt.Errorf("ecr:replication: rule %d needs between 1 and 25 destinations, got %d", i, len(rule.Destinations))
+		}
+		for _, destination := range rule.Destinations {
+			if destination.Region == "" {
+				return fmt.Errorf("ecr:replication: rule %d has a destination without a region", i)
+			}
+			if err := validateAccountIds("ecr:replication", []string{destination.AccountId}); err != nil {
+				return err
+			}
+		}
+	}
+	prefixes := map[string]bool{}
+	for _, rule := range a.PullThroughCache {
+		if rule.Prefix == "" || rule.UpstreamUrl == "" {
+			return fmt.Errorf("ecr:pullThroughCache: every rule needs a prefix and an upstreamUrl")
+		}
+		if prefixes[rule.Prefix] {
+			return fmt.Errorf("ecr:pullThroughCache: prefix %s is used more than once", rule.Prefix)
+		}
+		prefixes[rule.Prefix] = true
+	}
+	return validateAccountIds("ecr:allowReplicationFrom", a.AllowReplicationFrom)
+}
+
+// LoadECRRegistryArgs reads ecr:replication, ecr:pullThroughCache and
+// ecr:allowReplicationFrom. It returns nil when none of them is set.
+func LoadECRRegistryArgs(ctx *pulumi.Context) (*ECRRegistryArgs, error) {
+	config := config.New(ctx, "ecr")
+
+	var args ECRRegistryArgs
+	for _, setting := range []struct {
+		key   string
+		value interface{}
+	}{
+		{"replication", &args.Replication},
+		{"pullThroughCache", &args.PullThroughCache},
+		{"allowReplicationFrom", &args.AllowReplicationFrom},
+	} {
+		if err := config.TryObject(setting.key, setting.value); err != nil && config.Get(setting.key) != "" {
+			return nil, fmt.Errorf("ecr:%s: %w", setting.key, err)
+		}
+	}
+
+	if len(args.Replication) == 0 && len(args.PullThroughCache) == 0 && len(args.AllowReplicationFrom) == 0 {
+		return nil, nil
+	}
+	if err := args.Validate(); err != nil {
+		return nil, err
+	}
+	return &args, nil
+}
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Sid       string                 `json:"Sid,omitempty"`
+	Effect    string                 `json:"Effect"`
+	Principal map[string]interface{} `json:"Principal"`
+	Action    []string               `json:"Action"`
+	Resource  interface{}            `json:"Resource,omitempty"`
+}
+
+func (d policyDocument) json() (string, error) {
+	document, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(document), nil
+}
+
+func accountRoots(accountIds []string) []string {
+	roots := make([]string, len(accountIds))
+	for i, accountId := range accountIds {
+		roots[i] = "arn:aws:iam::" + accountId + ":root"
+	}
+	return roots
+}
+
+// pullPolicyDocument lets the accounts pull images from a repository.
+func pullPolicyDocument(accountIds []string) (string, error) {
+	return policyDocument{
+		Version: "2012-10-17",
+		Statement: []policyStatement{{
+			Sid:       "AllowPull",
+			Effect:    "Allow",
+			Principal: map[string]interface{}{"AWS": accountRoots(accountIds)},
+			Action:    []string{"ecr:BatchCheckLayerAvailability", "ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"},
+		}},
+	}.json()
+}
+
+// NewECRRegistry configures the registry of the current account and region.
+func NewECRRegistry(ctx *pulumi.Context, name string, args *ECRRegistryArgs, opts ...pulumi.ResourceOption) (_ *ECRRegistry, err error) {
+	var resource ECRRegistry
+
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("ecr registry %s: %w", name, err)
+		}
+	}()
+
+	if args == nil {
+		return nil, errors.New("registry args are required")
+	}
+	if err := args.Validate(); err != nil {
+		return nil, err
+	}
+
+	err = ctx.RegisterComponentResource("air-tek:infra:ecr-registry", name, &resource, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args.Replication) > 0 {
+		var rules ecr.ReplicationConfigurationReplicationConfigurationRuleArray
+		for _, rule := range args.Replication {
+			var destinations ecr.ReplicationConfigurationReplicationConfigurationRuleDestinationArray
+			for _, destination := range rule.Destinations {
+				destinations = append(destinations, ecr.ReplicationConfigurationReplicationConfigurationRuleDestinationArgs{
+					Region:     pulumi.String(destination.Region),
+					RegistryId: pulumi.String(destination.AccountId),
+				})
+			}
+			var filters ecr.ReplicationConfigurationReplicationConfigurationRuleRepositoryFilterArray
+			for _, prefix := range rule.RepositoryPrefixes {
+				filters = append(filters, ecr.ReplicationConfigurationReplicationConfigurationRuleRepositoryFilterArgs{
+					Filter:     pulumi.String(prefix),
+					FilterType: pulumi.String("PREFIX_MATCH"),
+				})
+			}
+			rules = append(rules, ecr.ReplicationConfigurationReplicationConfigurationRuleArgs{
+				Destinations:      destinations,
+				RepositoryFilters: filters,
+			})
+		}
+
+		_, err = ecr.NewReplicationConfiguration(ctx, name+"-replication", &ecr.ReplicationConfigurationArgs{
+			ReplicationConfiguration: &ecr.ReplicationConfigurationReplicationConfigurationArgs{
+				Rules: rules,
+			},
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", name+"-replication", err)
+		}
+	}
+
+	for _, rule := range args.PullThroughCache {
+		_, err = ecr.NewPullThroughCacheRule(ctx, name+"-"+rule.Prefix+"-cache", &ecr.PullThroughCacheRuleArgs{
+			EcrRepositoryPrefix: pulumi.String(rule.Prefix),
+			UpstreamRegistryUrl: pulumi.String(rule.UpstreamUrl),
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", name+"-"+rule.Prefix+"-cache", err)
+		}
+	}
+
+	if len(args.AllowReplicationFrom) > 0 {
+		region, err := aws.GetRegion(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("getting region: %w", err)
+		}
+		identity, err := aws.GetCallerIdentity(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("getting caller identity: %w", err)
+		}
+
+		policy, err := policyDocument{
+			Version: "2012-10-17",
+			Statement: []policyStatement{{
+				Sid:       "AllowReplication",
+				Effect:    "Allow",
+				Principal: map[string]interface{}{"AWS": accountRoots(args.AllowReplicationFrom)},
+				Action:    []string{"ecr:CreateRepository", "ecr:ReplicateImage"},
+				Resource:  fmt.Sprintf("arn:aws:ecr:%s:%s:repository/*", region.Name, identity.AccountId),
+			}},
+		}.json()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = ecr.NewRegistryPolicy(ctx, name+"-policy", &ecr.RegistryPolicyArgs{
+			Policy: pulumi.String(policy),
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", name+"-policy", err)
+		}
+	}
+
+	ctx.RegisterResourceOutputs(&resource, pulumi.Map{})
+
+	return &resource, nil
+}
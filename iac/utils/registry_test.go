@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"air-tek-iac/testutil"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestECRRegistry(t *testing.T) {
+	config := testutil.DefaultConfig()
+	config["ecr:replication"] = `[{"destinations": [{"region": "us-west-2", "accountId": "123456789012"}, {"region": "us-east-1", "accountId": "210987654321"}], "repositoryPrefixes": ["test-"]}]`
+	config["ecr:pullThroughCache"] = `[{"prefix": "ecr-public", "upstreamUrl": "public.ecr.aws"}]`
+	config["ecr:allowReplicationFrom"] = `["111111111111"]`
+
+	mocks, err := testutil.Run(config, func(ctx *pulumi.Context) error {
+		args, err := LoadECRRegistryArgs(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = NewECRRegistry(ctx, "test-ecr-registry", args)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replication := mocks.Resource(t, "aws:ecr/replicationConfiguration:ReplicationConfiguration", "test-ecr-registry-replication")
+	rules := replication.Inputs["replicationConfiguration"].ObjectValue()["rules"].ArrayValue()
+	if len(rules) != 1 {
+		t.Fatalf("got %d replication rules, want 1", len(rules))
+	}
+	rule := rules[0].ObjectValue()
+	if destinations := rule["destinations"].ArrayValue(); len(destinations) != 2 || destinations[1].ObjectValue()["registryId"].StringValue() != "210987654321" {
+		t.Errorf("destinations = %v", destinations)
+	}
+	if filters := rule["repositoryFilters"].ArrayValue(); len(filters) != 1 || filters[0].ObjectValue()["filterType"].StringValue() != "PREFIX_MATCH" {
+		t.Errorf("repository filters = %v", filters)
+	}
+
+	cache := mocks.Resource(t, "aws:ecr/pullThroughCacheRule:PullThroughCacheRule", "test-ecr-registry-ecr-public-cache")
+	if cache.StringValue("upstreamRegistryUrl") != "public.ecr.aws" {
+		t.Errorf("upstream = %q", cache.StringValue("upstreamRegistryUrl"))
+	}
+
+	policy := mocks.Resource(t, "aws:ecr/registryPolicy:RegistryPolicy", "test-ecr-registry-policy").StringValue("policy")
+	for _, want := range []string{"arn:aws:iam::111111111111:root", "ecr:ReplicateImage", "arn:aws:ecr:us-east-1:123456789012:repository/*"} {
+		if !strings.Contains(policy, want) {
+			t.Errorf("registry policy %s does not contain %s", policy, want)
+		}
+	}
+}
+
+func TestLoadECRRegistryArgs(t *testing.T) {
+	tests := map[string]struct {
+		key, value string
+	}{
+		"no destinations":         {"ecr:replication", `[{"destinations": []}]`},
+		"missing region":          {"ecr:replication", `[{"destinations": [{"accountId": "123456789012"}]}]`},
+		"bad destination account": {"ecr:replication", `[{"destinations": [{"region": "us-west-2", "accountId": "prod"}]}]`},
+		"cache without upstream":  {"ecr:pullThroughCache", `[{"prefix": "quay"}]`},
+		"duplicate cache prefix":  {"ecr:pullThroughCache", `[{"prefix": "quay", "upstreamUrl": "quay.io"}, {"prefix": "quay", "upstreamUrl": "quay.io"}]`},
+		"bad source account":      {"ecr:allowReplicationFrom", `["12345"]`},
+		"not a list":              {"ecr:replication", `{"region": "us-west-2"}`},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := testutil.DefaultConfig()
+			config[test.key] = test.value
+			_, err := testutil.Run(config, func(ctx *pulumi.Context) error {
+				_, err := LoadECRRegistryArgs(ctx)
+				return err
+			})
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	var args *ECRRegistryArgs
+	_, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		var err error
+		args, err = LoadECRRegistryArgs(ctx)
+		return err
+	})
+	if err != nil || args != nil {
+		t.Errorf("unconfigured registry = %+v, %v, want nil", args, err)
+	}
+}
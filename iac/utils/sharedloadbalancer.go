@@ -0,0 +1,335 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	elb "github.com/pulumi/pulumi-aws/sdk/v5/go/aws/elasticloadbalancingv2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// SharedLoadBalancer is an application load balancer several services sit
+// behind. Each service adds a LoadBalancerRoute with its own listener rule,
+// requests no rule matches get the default response.
+type SharedLoadBalancer struct {
+	pulumi.ResourceState
+
+	Url     pulumi.StringOutput `pulumi:"Url"`
+	DnsName pulumi.StringOutput `pulumi:"DnsName"`
+	// ListenerArn is the listener routes add their rules to, the HTTPS one
+	// when the load balancer has a domain name.
+	ListenerArn pulumi.StringOutput `pulumi:"ListenerArn"`
+	ArnSuffix   pulumi.StringOutput `pulumi:"ArnSuffix"`
+
+	vpcId pulumi.StringInput
+}
+
+type SharedLoadBalancerArgs struct {
+	LoadBalancerName string
+	VpcId            pulumi.StringInput
+	Subnets          pulumi.StringArrayInput
+	// SecurityGroups must include the load balancer security group of every
+	// routed service, as their tasks only accept traffic from those.
+	SecurityGroups pulumi.StringArrayInput
+	ListenerPort   int
+	Internal       bool
+	DomainName     string
+	HostedZoneId   string
+	SslPolicy      string
+	// DefaultResponse answers requests no rule matches, a plain 404 when
+	// unset.
+	DefaultResponse *FixedResponseArgs
+	// FixedResponses are answered by the load balancer itself, e.g. a
+	// robots.txt or a maintenance page.
+	FixedResponses []FixedResponseRule
+}
+
+// ListenerRuleArgs selects the requests of a rule. Host headers and path
+// patterns may contain * and ? wildcards, a request matches when it has one of
+// the host headers and one of the path patterns.
+type ListenerRuleArgs struct {
+	// Priority orders the rules of a listener from 1 to 50000, lower values
+	// are evaluated first and every rule needs its own.
+	Priority     int      `json:"priority"`
+	HostHeaders  []string `json:"hostHeaders"`
+	PathPatterns []string `json:"pathPatterns"`
+}
+
+type FixedResponseArgs struct {
+	// StatusCode is a 2XX, 4XX or 5XX code, 404 by default.
+	StatusCode int `json:"statusCode"`
+	// ContentType defaults to text/plain.
+	ContentType string `json:"contentType"`
+	MessageBody string `json:"messageBody"`
+}
+
+type FixedResponseRule struct {
+	ListenerRuleArgs
+	Response FixedResponseArgs `json:"response"`
+}
+
+// fixedResponseContentTypes are the content types ALB fixed responses allow.
+var fixedResponseContentTypes = []string{"text/plain", "text/css", "text/html", "application/javascript", "application/json"}
+
+func (a ListenerRuleArgs) Validate() error {
+	if a.Priority < 1 || a.Priority > 50000 {
+		return fmt.Errorf("rule priority must be between 1 and 50000, got %d", a.Priority)
+	}
+	if len(a.HostHeaders) == 0 && len(a.PathPatterns) == 0 {
+		return fmt.Errorf("rule %d needs host headers or path patterns", a.Priority)
+	}
+	// ALB counts every value of a rule's conditions against a limit of 5.
+	if values := len(a.HostHeaders) + len(a.PathPatterns); values > 5 {
+		return fmt.Errorf("rule %d has %d host headers and path patterns, ALB allows 5", a.Priority, values)
+	}
+	for _, value := range append(append([]string(nil), a.HostHeaders...), a.PathPatterns...) {
+		if value == "" || len(value) > 128 {
+			return fmt.Errorf("rule %d: host headers and path patterns must be 1 to 128 characters, got %q", a.Priority, value)
+		}
+	}
+	return nil
+}
+
+func (a FixedResponseArgs) withDefaults() FixedResponseArgs {
+	if a.StatusCode == 0 {
+		a.StatusCode = 404
+	}
+	if a.ContentType == "" {
+		a.ContentType = "text/plain"
+	}
+	return a
+}
+
+func (a FixedResponseArgs) Validate() error {
+	a = a.withDefaults()
+	if a.StatusCode < 200 || a.StatusCode > 599 || (a.StatusCode >= 300 && a.StatusCode < 400) {
+		return fmt.Errorf("fixed response status code must be 2XX, 4XX or 5XX, got %d", a.StatusCode)
+	}
+	if indexOf(fixedResponseContentTypes, a.ContentType) < 0 {
+		return fmt.Errorf("fixed response content type must be one of %v, got %q", fixedResponseContentTypes, a.ContentType)
+	}
+	if len(a.MessageBody) > 1024 {
+		return fmt.Errorf("fixed response message body is %d bytes, ALB allows 1024", len(a.MessageBody))
+	}
+	return nil
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *SharedLoadBalancerArgs) Validate() error {
+	if a.DomainName != "" && a.HostedZoneId == "" {
+		return fmt.Errorf("a hosted zone id is required with domain name %s", a.DomainName)
+	}
+	if a.DefaultResponse != nil {
+		if err := a.DefaultResponse.Validate(); err != nil {
+			return err
+		}
+	}
+	priorities := map[int]bool{}
+	for _, rule := range a.FixedResponses {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if err := rule.Response.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", rule.Priority, err)
+		}
+		if priorities[rule.Priority] {
+			return fmt.Errorf("rule priority %d is used more than once", rule.Priority)
+		}
+		priorities[rule.Priority] = true
+	}
+	return nil
+}
+
+func (r ListenerRuleArgs) conditions() elb.ListenerRuleConditionArray {
+	var conditions elb.ListenerRuleConditionArray
+	if len(r.HostHeaders) > 0 {
+		conditions = append(conditions, elb.ListenerRuleConditionArgs{
+			HostHeader: &elb.ListenerRuleConditionHostHeaderArgs{Values: pulumi.ToStringArray(r.HostHeaders)},
+		})
+	}
+	if len(r.PathPatterns) > 0 {
+		conditions = append(conditions, elb.ListenerRuleConditionArgs{
+			PathPattern: &elb.ListenerRuleConditionPathPatternArgs{Values: pulumi.ToStringArray(r.PathPatterns)},
+		})
+	}
+	return conditions
+}
+
+func NewSharedLoadBalancer(ctx *pulumi.Context, args *SharedLoadBalancerArgs, opts ...pulumi.ResourceOption) (_ *SharedLoadBalancer, err error) {
+	var resource SharedLoadBalancer
+
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("load balancer %s: %w", args.LoadBalancerName, err)
+		}
+	}()
+
+	if err := args.Validate(); err != nil {
+		return nil, err
+	}
+
+	err = ctx.RegisterComponentResource("air-tek:infra:shared-loadbalancer", args.LoadBalancerName, &resource, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	alb, err := elb.NewLoadBalancer(ctx, args.LoadBalancerName, &elb.LoadBalancerArgs{
+		Subnets:        args.Subnets,
+		SecurityGroups: args.SecurityGroups,
+		Internal:       pulumi.Bool(args.Internal),
+	}, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", args.LoadBalancerName, err)
+	}
+
+	defaultResponse := FixedResponseArgs{}
+	if args.DefaultResponse != nil {
+		defaultResponse = *args.DefaultResponse
+	}
+	defaultResponse = defaultResponse.withDefaults()
+
+	listener, url, err := newListeners(ctx, listenerArgs{
+		Name:         args.LoadBalancerName,
+		ListenerPort: args.ListenerPort,
+		DomainName:   args.DomainName,
+		HostedZoneId: args.HostedZoneId,
+		SslPolicy:    args.SslPolicy,
+		DefaultAction: elb.ListenerDefaultActionArgs{
+			Type: pulumi.String("fixed-response"),
+			FixedResponse: &elb.ListenerDefaultActionFixedResponseArgs{
+				StatusCode:  pulumi.String(strconv.Itoa(defaultResponse.StatusCode)),
+				ContentType: pulumi.String(defaultResponse.ContentType),
+				MessageBody: pulumi.String(defaultResponse.MessageBody),
+			},
+		},
+	}, alb, &resource)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range args.FixedResponses {
+		response := rule.Response.withDefaults()
+		ruleName := fmt.Sprintf("%s-rule-%d", args.LoadBalancerName, rule.Priority)
+		_, err = elb.NewListenerRule(ctx, ruleName, &elb.ListenerRuleArgs{
+			ListenerArn: listener.Arn,
+			Priority:    pulumi.Int(rule.Priority),
+			Conditions:  rule.conditions(),
+			Actions: elb.ListenerRuleActionArray{
+				elb.ListenerRuleActionArgs{
+					Type: pulumi.String("fixed-response"),
+					FixedResponse: &elb.ListenerRuleActionFixedResponseArgs{
+						StatusCode:  pulumi.String(strconv.Itoa(response.StatusCode)),
+						ContentType: pulumi.String(response.ContentType),
+						MessageBody: pulumi.String(response.MessageBody),
+					},
+				},
+			},
+		}, pulumi.Parent(&resource))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", ruleName, err)
+		}
+	}
+
+	resource.Url = url
+	resource.DnsName = alb.DnsName
+	resource.ListenerArn = listener.Arn
+	resource.ArnSuffix = alb.ArnSuffix
+	resource.vpcId = args.VpcId
+
+	ctx.RegisterResourceOutputs(&resource, pulumi.Map{
+		"Url":         url,
+		"DnsName":     alb.DnsName,
+		"ListenerArn": listener.Arn,
+		"ArnSuffix":   alb.ArnSuffix,
+	})
+
+	return &resource, nil
+}
+
+// LoadBalancerRoute forwards the requests matching its listener rule on a
+// SharedLoadBalancer to its own target group.
+type LoadBalancerRoute struct {
+	pulumi.ResourceState
+
+	// Url is the url of the shared load balancer, the rule decides which of
+	// its hosts and paths reach the route.
+	Url            pulumi.StringOutput `pulumi:"Url"`
+	TargetGroupArn pulumi.StringOutput `pulumi:"TargetGroupArn"`
+	// ResourceLabel identifies the load balancer and target group pair in
+	// CloudWatch request count metrics.
+	ResourceLabel pulumi.StringOutput `pulumi:"ResourceLabel"`
+}
+
+type LoadBalancerRouteArgs struct {
+	RouteName    string
+	LoadBalancer *SharedLoadBalancer
+	TargetPort   int
+	HealthCheck  HealthCheckArgs
+	Rule         ListenerRuleArgs
+}
+
+func NewLoadBalancerRoute(ctx *pulumi.Context, args *LoadBalancerRouteArgs, opts ...pulumi.ResourceOption) (_ *LoadBalancerRoute, err error) {
+	var resource LoadBalancerRoute
+
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("load balancer route %s: %w", args.RouteName, err)
+		}
+	}()
+
+	if args.LoadBalancer == nil {
+		return nil, errors.New("a shared load balancer is required")
+	}
+	if err := args.Rule.Validate(); err != nil {
+		return nil, err
+	}
+	if err := args.HealthCheck.Validate(); err != nil {
+		return nil, err
+	}
+
+	err = ctx.RegisterComponentResource("air-tek:infra:loadbalancer-route", args.RouteName, &resource, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	targetGroup, err := newTargetGroup(ctx, args.RouteName+"-tg", args.LoadBalancer.vpcId, args.TargetPort, args.HealthCheck, &resource)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = elb.NewListenerRule(ctx, args.RouteName+"-rule", &elb.ListenerRuleArgs{
+		ListenerArn: args.LoadBalancer.ListenerArn,
+		Priority:    pulumi.Int(args.Rule.Priority),
+		Conditions:  args.Rule.conditions(),
+		Actions: elb.ListenerRuleActionArray{
+			elb.ListenerRuleActionArgs{
+				Type:           pulumi.String("forward"),
+				TargetGroupArn: targetGroup.Arn,
+			},
+		},
+	}, pulumi.Parent(&resource))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", args.RouteName+"-rule", err)
+	}
+
+	resource.Url = args.LoadBalancer.Url
+	resource.TargetGroupArn = targetGroup.Arn
+	resource.ResourceLabel = pulumi.Sprintf("%s/%s", args.LoadBalancer.ArnSuffix, targetGroup.ArnSuffix)
+
+	ctx.RegisterResourceOutputs(&resource, pulumi.Map{
+		"Url":            resource.Url,
+		"TargetGroupArn": targetGroup.Arn,
+		"ResourceLabel":  resource.ResourceLabel,
+	})
+
+	return &resource, nil
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"air-tek-iac/testutil"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const listenerRuleType = "aws:elasticloadbalancingv2/listenerRule:ListenerRule"
+
+func TestSharedLoadBalancer(t *testing.T) {
+	var apiUrl, label string
+	mocks, err := testutil.Run(testutil.DefaultConfig(), func(ctx *pulumi.Context) error {
+		lb, err := NewSharedLoadBalancer(ctx, &SharedLoadBalancerArgs{
+			LoadBalancerName: "test-lb",
+			VpcId:            pulumi.String("vpc-1"),
+			ListenerPort:     80,
+			FixedResponses: []FixedResponseRule{{
+				ListenerRuleArgs: ListenerRuleArgs{Priority: 1, PathPatterns: []string{"/robots.txt"}},
+				Response:         FixedResponseArgs{StatusCode: 200, MessageBody: "User-agent: *"},
+			}},
+		})
+		if err != nil {
+			return err
+		}
+		for _, route := range []LoadBalancerRouteArgs{
+			{RouteName: "api", Rule: ListenerRuleArgs{Priority: 10, PathPatterns: []string{"/WeatherForecast*"}}},
+			{RouteName: "ui", Rule: ListenerRuleArgs{Priority: 20, HostHeaders: []string{"ui.example.com"}, PathPatterns: []string{"/*"}}},
+		} {
+			route.LoadBalancer = lb
+			route.TargetPort = 5000
+			created, err := NewLoadBalancerRoute(ctx, &route)
+			if err != nil {
+				return err
+			}
+			if route.RouteName == "api" {
+				created.Url.ApplyT(func(value string) string {
+					apiUrl = value
+					return value
+				})
+				created.ResourceLabel.ApplyT(func(value string) string {
+					label = value
+					return value
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if albs := mocks.Resources(loadBalancerType); len(albs) != 1 {
+		t.Fatalf("got %d load balancers, want 1", len(albs))
+	}
+	if apiUrl != "http://test-lb.elb.amazonaws.com" {
+		t.Errorf("route url = %q", apiUrl)
+	}
+	if label != "app/test-lb/1234/targetgroup/api-tg/5678" {
+		t.Errorf("route resource label = %q", label)
+	}
+
+	action := mocks.Resource(t, listenerType, "test-lb-listener").Objects("defaultActions")[0]
+	if action["type"].StringValue() != "fixed-response" || action["fixedResponse"].ObjectValue()["statusCode"].StringValue() != "404" {
+		t.Errorf("default action = %v, want a fixed 404", action)
+	}
+
+	robots := mocks.Resource(t, listenerRuleType, "test-lb-rule-1").Objects("actions")[0]
+	if robots["type"].StringValue() != "fixed-response" || robots["fixedResponse"].ObjectValue()["statusCode"].StringValue() != "200" {
+		t.Errorf("robots.txt action = %v, want a fixed 200", robots)
+	}
+
+	ui := mocks.Resource(t, listenerRuleType, "ui-rule")
+	if priority := ui.Inputs["priority"]; !priority.IsNumber() || priority.NumberValue() != 20 {
+		t.Errorf("ui rule priority = %v, want 20", priority)
+	}
+	if conditions := ui.Objects("conditions"); len(conditions) != 2 {
+		t.Errorf("ui rule has %d conditions, want a host header and a path pattern", len(conditions))
+	}
+	forward := ui.Objects("actions")[0]
+	if forward["type"].StringValue() != "forward" || !strings.HasSuffix(forward["targetGroupArn"].StringValue(), "/ui-tg") {
+		t.Errorf("ui rule action = %v, want a forward to ui-tg", forward)
+	}
+}
+
+func TestListenerRuleArgsValidate(t *testing.T) {
+	tests := map[string]struct {
+		args  ListenerRuleArgs
+		valid bool
+	}{
+		"path":          {ListenerRuleArgs{Priority: 1, PathPatterns: []string{"/api/*"}}, true},
+		"host and path": {ListenerRuleArgs{Priority: 50000, HostHeaders: []string{"*.example.com"}, PathPatterns: []string{"/"}}, true},
+		"no priority":   {ListenerRuleArgs{PathPatterns: []string{"/"}}, false},
+		"no conditions": {ListenerRuleArgs{Priority: 1}, false},
+		"too many":      {ListenerRuleArgs{Priority: 1, PathPatterns: []string{"/a", "/b", "/c", "/d", "/e", "/f"}}, false},
+		"empty value":   {ListenerRuleArgs{Priority: 1, HostHeaders: []string{""}}, false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := test.args.Validate(); (err == nil) != test.valid {
+				t.Errorf("err = %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestSharedLoadBalancerRejectsInvalidResponses(t *testing.T) {
+	tests := map[string]*SharedLoadBalancerArgs{
+		"redirect status": {DefaultResponse: &FixedResponseArgs{StatusCode: 301}},
+		"content type":    {DefaultResponse: &FixedResponseArgs{ContentType: "image/png"}},
+		"same priority": {FixedResponses: []FixedResponseRule{
+			{ListenerRuleArgs: ListenerRuleArgs{Priority: 1, PathPatterns: []string{"/a"}}},
+			{ListenerRuleArgs: ListenerRuleArgs{Priority: 1, PathPatterns: []string{"/b"}}},
+		}},
+	}
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := args.Validate(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ecs"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	FargateLaunchType = "FARGATE"
+	awsvpcNetworkMode = "awsvpc"
+
+	// DefaultCpu and DefaultMemory size tasks that don't set their own, the
+	// smallest Fargate size.
+	DefaultCpu    = "256"
+	DefaultMemory = "512"
+)
+
+// fargateMemory lists the memory sizes in MiB Fargate supports for each cpu
+// size in units.
+var fargateMemory = map[int][]int{
+	256:   {512, 1024, 2048},
+	512:   memorySizes(1024, 4096, 1024),
+	1024:  memorySizes(2048, 8192, 1024),
+	2048:  memorySizes(4096, 16384, 1024),
+	4096:  memorySizes(8192, 30720, 1024),
+	8192:  memorySizes(16384, 61440, 4096),
+	16384: memorySizes(32768, 122880, 8192),
+}
+
+func memorySizes(first int, last int, step int) []int {
+	var sizes []int
+	for size := first; size <= last; size += step {
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+// ValidateFargateSize checks that Fargate offers the cpu and memory
+// combination.
+func ValidateFargateSize(cpu string, memory string) error {
+	cpuUnits, err := strconv.Atoi(cpu)
+	if err != nil {
+		return fmt.Errorf("cpu %q is not a number of cpu units", cpu)
+	}
+	memoryMiB, err := strconv.Atoi(memory)
+	if err != nil {
+		return fmt.Errorf("memory %q is not a number of MiB", memory)
+	}
+	sizes, ok := fargateMemory[cpuUnits]
+	if !ok {
+		return fmt.Errorf("Fargate has no %d cpu size, expected 256, 512, 1024, 2048, 4096, 8192 or 16384", cpuUnits)
+	}
+	for _, size := range sizes {
+		if size == memoryMiB {
+			return nil
+		}
+	}
+	return fmt.Errorf("Fargate can't run %d cpu with %d MiB, expected %d to %d MiB", cpuUnits, memoryMiB, sizes[0], sizes[len(sizes)-1])
+}
+
+// validateLaunch checks that the service can launch its task definition, so
+// a mismatch fails the preview instead of the service deployment.
+func validateLaunch(taskDefinition *ecs.TaskDefinitionArgs, service *ecs.ServiceArgs) error {
+	launchType, _ := service.LaunchType.(pulumi.String)
+	compatibilities, _ := taskDefinition.RequiresCompatibilities.(pulumi.StringArray)
+	compatible := false
+	for _, compatibility := range compatibilities {
+		if compatibility == launchType {
+			compatible = true
+		}
+	}
+	if !compatible {
+		return fmt.Errorf("launch type %q is not among the task definition's compatibilities %v", launchType, compatibilities)
+	}
+	if launchType != FargateLaunchType {
+		return nil
+	}
+	if networkMode, _ := taskDefinition.NetworkMode.(pulumi.String); networkMode != awsvpcNetworkMode {
+		return fmt.Errorf("Fargate tasks need the %s network mode, got %q", awsvpcNetworkMode, networkMode)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ecs"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestValidateFargateSize(t *testing.T) {
+	if err := ValidateFargateSize("1024", "3072"); err != nil {
+		t.Errorf("1024 cpu with 3072 MiB: %v", err)
+	}
+
+	tests := map[string][2]string{
+		"unsupported cpu":   {"300", "512"},
+		"too little memory": {"1024", "1024"},
+		"too much memory":   {"1024", "9216"},
+		"memory off step":   {"1024", "2560"},
+		"smallest size":     {"256", "1536"},
+		"cpu not a number":  {"one", "512"},
+	}
+	for name, size := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateFargateSize(size[0], size[1]); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestValidateLaunch(t *testing.T) {
+	fargate := func() (*ecs.TaskDefinitionArgs, *ecs.ServiceArgs) {
+		return &ecs.TaskDefinitionArgs{
+			NetworkMode:             pulumi.String(awsvpcNetworkMode),
+			RequiresCompatibilities: pulumi.StringArray{pulumi.String(FargateLaunchType)},
+		}, &ecs.ServiceArgs{
+			LaunchType: pulumi.String(FargateLaunchType),
+		}
+	}
+	if err := validateLaunch(fargate()); err != nil {
+		t.Errorf("fargate launch: %v", err)
+	}
+
+	tests := map[string]func(taskDefinition *ecs.TaskDefinitionArgs, service *ecs.ServiceArgs){
+		"ec2 task definition": func(d *ecs.TaskDefinitionArgs, _ *ecs.ServiceArgs) {
+			d.RequiresCompatibilities = pulumi.StringArray{pulumi.String("EC2")}
+		},
+		"no compatibilities":  func(d *ecs.TaskDefinitionArgs, _ *ecs.ServiceArgs) { d.RequiresCompatibilities = nil },
+		"ec2 launch type":     func(_ *ecs.TaskDefinitionArgs, s *ecs.ServiceArgs) { s.LaunchType = pulumi.String("EC2") },
+		"bridge network mode": func(d *ecs.TaskDefinitionArgs, _ *ecs.ServiceArgs) { d.NetworkMode = pulumi.String("bridge") },
+		"no network mode":     func(d *ecs.TaskDefinitionArgs, _ *ecs.ServiceArgs) { d.NetworkMode = nil },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			taskDefinition, service := fargate()
+			change(taskDefinition, service)
+			if err := validateLaunch(taskDefinition, service); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ namespace infra_web
         // For more information on how to configure your application, visit https://go.microsoft.com/fwlink/?LinkID=398940
         public void ConfigureServices(IServiceCollection services)
         {
+            services.AddHealthChecks();
         }
 
         // This method gets called by the runtime. Use this method to configure the HTTP request pipeline.
@@ -40,6 +41,9 @@ namespace infra_web
 
             app.UseEndpoints(endpoints =>
             {
+                // The load balancer checks this route, it doesn't call the API so an API outage
+                // doesn't take the UI tasks out of service.
+                endpoints.MapHealthChecks("/health");
                 endpoints.MapGet("/", async context =>
                 {
                     using var hc = new HttpClient();